@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/persistence"
+)
+
+// version wird beim Bauen gesetzt: go build -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+const usage = `Verwendung: wavely <befehl> [flags]
+
+Befehle:
+  serve            Server starten (Standard ohne Befehl)
+  config check     Konfigurationsdatei prüfen
+  config schema    JSON-Schema der Konfiguration ausgeben
+  jobs export      ausstehende Jobs aus dem Cache ausgeben
+  jobs import      Jobs in den Cache übernehmen (bei gestopptem Server)
+  simulate         Lastkurven der Backoff-Strategien vergleichen
+  version          Version ausgeben
+
+Pfade und Port lassen sich auch über WAVELY_CONFIG, WAVELY_CACHE_DIR,
+WAVELY_LOG_DIR und WAVELY_PORT setzen; Flags haben Vorrang.
+"wavely <befehl> -h" zeigt die Flags eines Befehls.
+`
+
+// run wählt den Unterbefehl aus und liefert den Exit-Code. Ohne Befehl wird der
+// Server gestartet, damit bestehende Aufrufe unverändert funktionieren.
+func run(args []string) int {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		return runServe(args)
+	case "config":
+		return runConfig(args)
+	case "jobs":
+		return runJobs(args)
+	case "simulate":
+		return runSimulate(args)
+	case "version":
+		return runVersion(os.Stdout)
+	case "help":
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, "Unbekannter Befehl:", command)
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// paths sind die Verzeichnisse, die über Flags und Umgebungsvariablen
+// überschrieben werden können.
+type paths struct {
+	config   *string
+	cacheDir *string
+	logDir   *string
+}
+
+func configFlag(flags *flag.FlagSet) *string {
+	return flags.String("config", os.Getenv("WAVELY_CONFIG"), "Konfigurationsdatei (Standard: /app/config/wavely.cfg.yaml) [WAVELY_CONFIG]")
+}
+
+func cacheDirFlag(flags *flag.FlagSet) *string {
+	return flags.String("cache-dir", envOr("WAVELY_CACHE_DIR", persistence.DefaultCacheDir), "Verzeichnis für persistierte Jobs und Zustände [WAVELY_CACHE_DIR]")
+}
+
+func pathFlags(flags *flag.FlagSet) paths {
+	return paths{
+		config:   configFlag(flags),
+		cacheDir: cacheDirFlag(flags),
+		logDir:   flags.String("log-dir", os.Getenv("WAVELY_LOG_DIR"), "Verzeichnis der Logdatei (Standard: /app/logs bzw. ./log mit debug) [WAVELY_LOG_DIR]"),
+	}
+}
+
+func (p paths) apply() {
+	config.ConfigFile = *p.config
+	persistence.SetCacheDir(*p.cacheDir)
+	logger.Dir = *p.logDir
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func runVersion(w io.Writer) int {
+	revision := ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = " (" + setting.Value + ")"
+			}
+		}
+	}
+	fmt.Fprintf(w, "wavely %s%s %s\n", version, revision, runtime.Version())
+	return 0
+}
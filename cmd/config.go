@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"djp.chapter42.de/a/internal/config"
+)
+
+// runConfig führt "wavely config check" bzw. "wavely config schema" aus und
+// liefert den Exit-Code.
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Verwendung: wavely config check [-config datei] | wavely config schema")
+		return 2
+	}
+
+	switch args[0] {
+	case "check":
+		flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+		file := configFlag(flags)
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if err := config.Check(*file); err != nil {
+			printConfigError(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("Konfiguration gültig.")
+		return 0
+	case "schema":
+		schema, err := config.Schema()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Fehler beim Erzeugen des Schemas:", err)
+			return 1
+		}
+		fmt.Println(string(schema))
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, "Unbekannter Befehl:", args[0])
+		return 2
+	}
+}
+
+// printConfigError gibt bei einer ungültigen Konfiguration jedes Feld in einer
+// eigenen Zeile aus.
+func printConfigError(w io.Writer, err error) {
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) {
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintf(w, "Konfiguration ungültig (%d Fehler):\n", len(invalid.Errors))
+	for _, fieldErr := range invalid.Errors {
+		fmt.Fprintln(w, "  "+fieldErr.Error())
+	}
+}
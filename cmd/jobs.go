@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/persistence"
+)
+
+// runJobs führt "wavely jobs export" bzw. "wavely jobs import" aus. Beide
+// arbeiten direkt auf der Datei im Cache; ein laufender Server überschreibt
+// sie beim Herunterfahren, daher nur bei gestopptem Server importieren.
+func runJobs(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Verwendung: wavely jobs export [-out datei] | wavely jobs import datei")
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		flags := flag.NewFlagSet("jobs export", flag.ContinueOnError)
+		cacheDir := cacheDirFlag(flags)
+		out := flags.String("out", "-", "Zieldatei (- für stdout)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		persistence.SetCacheDir(*cacheDir)
+		return exportJobs(*out)
+	case "import":
+		flags := flag.NewFlagSet("jobs import", flag.ContinueOnError)
+		cacheDir := cacheDirFlag(flags)
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if flags.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Verwendung: wavely jobs import [-cache-dir verzeichnis] datei (- für stdin)")
+			return 2
+		}
+		persistence.SetCacheDir(*cacheDir)
+		return importJobs(flags.Arg(0))
+	default:
+		fmt.Fprintln(os.Stderr, "Unbekannter Befehl:", args[0])
+		return 2
+	}
+}
+
+func exportJobs(out string) int {
+	jobs, err := persistence.ReadPendingJobs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Fehler beim Lesen der ausstehenden Jobs:", err)
+		return 1
+	}
+	if jobs == nil {
+		jobs = []data.PendingJob{}
+	}
+
+	err = writeOutput(out, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jobs)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Fehler beim Exportieren der Jobs:", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d Jobs exportiert.\n", len(jobs))
+	return 0
+}
+
+// importJobs übernimmt exportierte Jobs in die Datei der ausstehenden Jobs.
+// Bereits vorhandene IDs werden übersprungen, fehlende IDs ergänzt.
+func importJobs(in string) int {
+	var content []byte
+	var err error
+	if in == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(in)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Fehler beim Lesen der Importdatei:", err)
+		return 1
+	}
+
+	var imported []data.PendingJob
+	if err := json.Unmarshal(content, &imported); err != nil {
+		fmt.Fprintln(os.Stderr, "Fehler beim Deserialisieren der Importdatei:", err)
+		return 1
+	}
+
+	jobs, err := persistence.ReadPendingJobs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Fehler beim Lesen der ausstehenden Jobs:", err)
+		return 1
+	}
+	known := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		known[job.ID] = true
+	}
+
+	added, skipped := 0, 0
+	for _, job := range imported {
+		if job.Job.UID == "" || known[job.ID] {
+			skipped++
+			continue
+		}
+		if job.ID == "" {
+			job.ID = data.NewJobID()
+		}
+		if job.CreatedAt.IsZero() {
+			job.CreatedAt = time.Now()
+		}
+		known[job.ID] = true
+		jobs = append(jobs, job)
+		added++
+	}
+
+	if added > 0 {
+		if err := persistence.WritePendingJobs(jobs); err != nil {
+			fmt.Fprintln(os.Stderr, "Fehler beim Speichern der ausstehenden Jobs:", err)
+			return 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d Jobs importiert, %d übersprungen.\n", added, skipped)
+	return 0
+}
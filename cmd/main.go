@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,10 +12,14 @@ import (
 	"time"
 
 	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/control"
 	"djp.chapter42.de/a/internal/data"
 	"djp.chapter42.de/a/internal/handlers"
+	"djp.chapter42.de/a/internal/idempotency"
 	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/metrics"
 	"djp.chapter42.de/a/internal/persistence"
+	"djp.chapter42.de/a/internal/poller"
 	"djp.chapter42.de/a/internal/processor"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
@@ -27,8 +32,24 @@ var (
 )
 
 func main() {
-	// Konfiguration laden
-	config.InitConfig(logger.Log)
+	os.Exit(run(os.Args[1:]))
+}
+
+// runServe führt "wavely serve" aus und liefert den Exit-Code.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	paths := pathFlags(flags)
+	flags.StringVar(&config.Port, "port", os.Getenv("WAVELY_PORT"), "Port des Servers (Standard: port aus der Konfiguration) [WAVELY_PORT]")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths.apply()
+
+	// Konfiguration laden und prüfen, bei Fehlern nicht starten
+	if err := config.InitConfig(); err != nil {
+		printConfigError(os.Stderr, err)
+		return 1
+	}
 
 	// Setzt den Debug Mode
 	debugMode := config.Config.Debug
@@ -37,26 +58,74 @@ func main() {
 	logger.InitLogger(debugMode)
 	defer logger.Log.Sync()
 
-	// Geladene Jobs wiederherstellen
-	persistence.RestorePendingJobs(&jobsMutex, &pendingJobs, &config.Config.Current)
+	// Pausierte Targets und Wartungsmodus vor dem Start der Worker übernehmen
+	persistence.RestoreControls(control.State)
+
+	// Nach einem Absturz noch gehaltene Sperren freigeben, bevor neue erworben werden
+	processor.LeaseFile = persistence.LeaseFileName
+	processor.RecoverLocks(&config.Config.Current)
 
 	// Start des Workerpools zum parallelen Verarbeiten der Jobs
 	processor.StartWorkerPool(&pendingJobs, &jobsMutex, config.Config)
 
+	// Idempotency-Keys und geladene Jobs wiederherstellen
+	idempotency.Keys.SetWindow(config.Config.Current.Idempotency.Window)
+	persistence.RestoreIdempotencyKeys(idempotency.Keys)
+	persistence.RestorePendingJobs(&jobsMutex, &pendingJobs, &config.Config.Current)
+
+	// Nach einem Absturz ausgelagerte Jobs übernehmen
+	processor.RecoverSpill(&pendingJobs, &jobsMutex)
+
+	// Wiederkehrende Abfragen aus der Konfiguration und der letzten Laufzeit starten
+	poller.Pollers = poller.NewManager(&config.Config.Current)
+	for _, pc := range config.Config.Current.Pollers {
+		if err := poller.Pollers.Start(pc, false); err != nil {
+			logger.Log.Error("Poller konnte nicht gestartet werden:", zap.String("name", pc.Name), zap.Error(err))
+		}
+	}
+	persistence.RestorePollers(poller.Pollers)
+
+	// Änderungen an der Konfiguration ohne Neustart übernehmen
+	config.OnReload(func(cfg *data.WavelyConfig) {
+		processor.Apply(&cfg.Current)
+		idempotency.Keys.SetWindow(cfg.Current.Idempotency.Window)
+		poller.Pollers.Reload(&cfg.Current)
+	})
+	if err := config.Watch(); err != nil {
+		logger.Log.Error("Konfigurationsdatei kann nicht überwacht werden:", zap.Error(err))
+	}
+
 	// Gin-Router initialisieren
 	router := gin.Default()
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", handlers.IdempotencyHeader, handlers.ClientHeader},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	router.GET("/health", handlers.HealthHandler())
+	router.GET("/metrics", metrics.Handler())
 	router.POST("/jobs", handlers.NewJobHandler(&jobsMutex, &pendingJobs))
+	router.POST("/jobs/batch", handlers.NewBatchJobHandler(&jobsMutex, &pendingJobs))
+	router.GET("/jobs", handlers.ListJobsHandler(&jobsMutex, &pendingJobs))
+	router.GET("/jobs/:id", handlers.GetJobHandler(&jobsMutex, &pendingJobs))
+	router.DELETE("/jobs/:id", handlers.CancelJobHandler(&jobsMutex, &pendingJobs))
+	router.POST("/jobs/:id/requeue", handlers.RequeueJobHandler(&jobsMutex, &pendingJobs))
+	router.POST("/targets/:name/transform", handlers.TransformHandler())
+	router.GET("/pollers", handlers.ListPollersHandler(poller.Pollers))
+	router.POST("/pollers", handlers.NewPollerHandler(poller.Pollers))
+	router.DELETE("/pollers/:name", handlers.DeletePollerHandler(poller.Pollers))
+	router.GET("/pollers/events", handlers.PollerEventsHandler(poller.Pollers))
+	router.GET("/admin/controls", handlers.ControlStatusHandler(control.State))
+	router.POST("/admin/targets/:name/:action", handlers.TargetControlHandler(control.State))
+	router.POST("/admin/maintenance", handlers.MaintenanceHandler(control.State, true))
+	router.DELETE("/admin/maintenance", handlers.MaintenanceHandler(control.State, false))
+	router.GET("/admin/config", handlers.ConfigStatusHandler())
+	router.POST("/admin/config/reload", handlers.ReloadConfigHandler())
 
 	// Server starten
 	port := config.Config.Port
@@ -68,19 +137,33 @@ func main() {
 	// Goroutine für das Abfangen von Shutdown-Signalen
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-quit
 		logger.Log.Info("Server wird heruntergefahren...")
 
-		// Offene Jobs sichern
-		persistence.SavePendingJobs(&jobsMutex, &pendingJobs)
-
+		// Keine neuen Jobs und Abfragen mehr annehmen
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-
 		if err := srv.Shutdown(ctx); err != nil {
-			logger.Log.Fatal("Server-Shutdown fehlgeschlagen:", zap.Error(err))
+			logger.Log.Error("Server-Shutdown fehlgeschlagen:", zap.Error(err))
+		}
+		persistence.SavePollers(poller.Pollers)
+		poller.Pollers.StopAll()
+
+		// Wartezeiten abbrechen und laufende Schreibvorgänge abschließen lassen.
+		// Nach Ablauf der Frist die Aufrufe abbrechen, damit nach dem Sichern der
+		// Jobs kein Schreibvorgang mehr beim Target ankommt.
+		if !processor.Shutdown(config.Active().Shutdown.GracePeriod) {
+			processor.Abort()
 		}
+		// Sperren noch laufender oder gescheiterter Freigaben nicht stehen lassen
+		processor.ReleaseLocks(&config.Active().Current)
+
+		// Erst danach die verbleibenden Jobs sichern
+		persistence.SavePendingJobs(&jobsMutex, &pendingJobs)
+		persistence.SaveIdempotencyKeys(idempotency.Keys)
 
 		logger.Log.Info("Server heruntergefahren.")
 	}()
@@ -90,4 +173,6 @@ func main() {
 	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 		logger.Log.Fatal("Fehler beim Starten des Servers:", zap.Error(err))
 	}
+	<-stopped
+	return 0
 }
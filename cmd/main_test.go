@@ -2,19 +2,27 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"djp.chapter42.de/a/internal/clock"
 	"djp.chapter42.de/a/internal/config"
 	"djp.chapter42.de/a/internal/data"
 	"djp.chapter42.de/a/internal/external"
 	"djp.chapter42.de/a/internal/handlers"
 	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/persistence"
+	"djp.chapter42.de/a/internal/processor"
+	"djp.chapter42.de/a/internal/tmpl"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -66,7 +74,11 @@ func TestHandleNewJob(t *testing.T) {
 	jobsMutex.Lock()
 	assert.Len(t, pendingJobs, 1)
 	assert.Equal(t, response["uid"], pendingJobs[0].Job.UID)
-	assert.Equal(t, map[string]interface{}{"key": "value"}, pendingJobs[0].Job.Data)
+	// JSON wird unverändert als Payload übernommen
+	payload, err := base64.StdEncoding.DecodeString(pendingJobs[0].Job.Data)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"key": "value"}`, string(payload))
+	assert.Equal(t, "json", pendingJobs[0].Job.ContentType)
 	jobsMutex.Unlock()
 
 	// Test mit expliziter UID
@@ -98,7 +110,10 @@ func TestCheckWritable(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer tsOK.Close()
+	logger.Log = initTestLogger()
 	config.Config = &data.WavelyConfig{}
+	config.Config.Current.Endpoints = data.EndpointConfig{Check: "/objects/{{.UID}}/writable"}
+	assert.NoError(t, tmpl.PrepareTemplates(config.Config))
 	config.Config.Current.BaseURL = tsOK.URL
 
 	writable, err := external.WriteCheck(&data.Job{UID: "test-uid"}, &config.Config.Current)
@@ -132,20 +147,38 @@ func TestCheckWritable(t *testing.T) {
 	writable, err = external.WriteCheck(&data.Job{UID: "test-uid"}, &config.Config.Current)
 	assert.Error(t, err)
 	assert.False(t, writable)
+
+	// Testfall: Endpunkt nicht konfiguriert, statt still die Basis-URL zu prüfen
+	config.Config.Current.BaseURL = tsOK.URL
+	config.Config.Current.ParsedCheckTpl = nil
+	writable, err = external.WriteCheck(&data.Job{UID: "test-uid"}, &config.Config.Current)
+	assert.ErrorIs(t, err, tmpl.ErrNoEndpoint)
+	assert.False(t, writable)
 }
 
 func TestWriteData(t *testing.T) {
-	// Testfall: Erfolgreiches Schreiben (Status 2xx)
+	logger.Log = initTestLogger()
+
+	// Testfall: Erfolgreiches Schreiben (Status 2xx) an den Write-Endpunkt, der
+	// Payload kommt dekodiert an
+	var written []byte
+	var path string
 	tsSuccess := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, http.MethodPut, r.Method)
+		path = r.URL.Path
+		written, _ = io.ReadAll(r.Body)
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer tsSuccess.Close()
 	config.Config = &data.WavelyConfig{}
+	config.Config.Current.Endpoints = data.EndpointConfig{Check: "/objects/{{.UID}}/writable", Write: "/objects/{{.UID}}"}
+	assert.NoError(t, tmpl.PrepareTemplates(config.Config))
 	config.Config.Current.BaseURL = tsSuccess.URL
 
-	err := external.WriteData(&data.Job{UID: "test-uid"}, "value", &config.Config.Current)
+	err := external.WriteData(&data.Job{UID: "test-uid"}, "dmFsdWU=", &config.Config.Current)
 	assert.NoError(t, err)
+	assert.Equal(t, "/objects/test-uid", path)
+	assert.Equal(t, "value", string(written))
 
 	// Testfall: Fehler beim Schreiben (Status nicht 2xx)
 	tsError := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -155,23 +188,26 @@ func TestWriteData(t *testing.T) {
 	defer tsError.Close()
 	config.Config.Current.BaseURL = tsError.URL
 
-	err = external.WriteData(&data.Job{UID: "test-uid"}, "value", &config.Config.Current)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "Status: 500 Internal Server Error")
-	assert.Contains(t, err.Error(), "Body: Write error")
+	err = external.WriteData(&data.Job{UID: "test-uid"}, "dmFsdWU=", &config.Config.Current)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "500 Internal Server Error")
+		assert.Contains(t, err.Error(), "Body: Write error")
+	}
 
-	// Testfall: Fehler beim Serialisieren der Daten
-	config.Config.Current.BaseURL = tsSuccess.URL // Verwenden Sie eine gültige URL, um den HTTP-Aufruf zu ermöglichen
-	invalidData := ""
-	err = external.WriteData(&data.Job{UID: "test-uid"}, invalidData, &config.Config.Current)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "fehler beim Serialisieren der Daten zu JSON")
+	// Testfall: Payload ist kein gültiges Base64, es wird nichts gesendet
+	config.Config.Current.BaseURL = tsSuccess.URL
+	written = nil
+	err = external.WriteData(&data.Job{UID: "test-uid"}, "value", &config.Config.Current)
+	var corrupt base64.CorruptInputError
+	assert.ErrorAs(t, err, &corrupt)
+	assert.Nil(t, written)
 
 	// Testfall: Fehler beim Erstellen der Anfrage
 	config.Config.Current.BaseURL = "%invalid-url" // Ungültige URL
-	err = external.WriteData(&data.Job{UID: "test-uid"}, "value", &config.Config.Current)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "fehler beim Erstellen der PUT-Anfrage")
+	err = external.WriteData(&data.Job{UID: "test-uid"}, "dmFsdWU=", &config.Config.Current)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid URL escape")
+	}
 }
 
 /* func TestSaveAndRestorePendingJobs(t *testing.T) {
@@ -211,16 +247,55 @@ func TestWriteData(t *testing.T) {
 } */
 
 func TestProcessJobs(t *testing.T) {
+	logger.Log = initTestLogger()
+
 	// Aufräumen: Stellen Sie sicher, dass pendingJobs leer ist
 	jobsMutex.Lock()
 	pendingJobs = []data.PendingJob{}
 	jobsMutex.Unlock()
 
-	// Mock-HTTP-Client erstellen
+	// Mock-HTTP-Client erstellen; die Aufrufe laufen über den Standard-Transport
 	mockClient := new(MockHTTPClient)
-	httpClient = &http.Client{Transport: &mockTransport{client: mockClient}} // Verwenden Sie den Mock-Transport
+	transport := http.DefaultTransport
+	http.DefaultTransport = &mockTransport{client: mockClient}
+	defer func() { http.DefaultTransport = transport }()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "process-target",
+		BaseURL:   "http://wavely.test",
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}/writable", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+	current.Random = clock.NewRandom(1)
+
+	revision := func(uid string) {
+		mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.URL.Path == "/revision/"+uid && req.Method == http.MethodGet
+		})).Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"latest_revision": "` + uid + `"}`))}, nil).Once()
+	}
+
+	// process startet die Verarbeitung und spult den Backoff vor dem ersten Versuch vor
+	process := func(job data.PendingJob) chan struct{} {
+		jobsMutex.Lock()
+		pendingJobs = append(pendingJobs, job)
+		jobsMutex.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			processor.ProcessJob(job, &pendingJobs, &jobsMutex, current)
+			close(done)
+		}()
+		fake.BlockUntil(1)
+		wait, _ := fake.NextWait()
+		fake.Advance(wait)
+		return done
+	}
 
 	// Testfall: Erfolgreiche Verarbeitung eines Jobs
+	revision("test-uid")
 	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
 		return strings.Contains(req.URL.Path, "/objects/test-uid/writable") && req.Method == http.MethodGet
 	})).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil).Once()
@@ -228,12 +303,7 @@ func TestProcessJobs(t *testing.T) {
 		return strings.Contains(req.URL.Path, "/objects/test-uid") && req.Method == http.MethodPut
 	})).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil).Once()
 
-	jobsMutex.Lock()
-	pendingJobs = append(pendingJobs, data.PendingJob{Job: data.Job{UID: "test-uid", Data: "value"}, CreatedAt: time.Now()})
-	jobsMutex.Unlock()
-
-	// Kurze Zeit warten, um die Verarbeitung zu ermöglichen
-	time.Sleep(100 * time.Millisecond)
+	<-process(data.PendingJob{ID: "test-job", Job: data.Job{UID: "test-uid", Data: "dmFsdWU="}, CreatedAt: fake.Now()})
 
 	jobsMutex.Lock()
 	assert.Empty(t, pendingJobs) // Job sollte verarbeitet und entfernt worden sein
@@ -241,21 +311,28 @@ func TestProcessJobs(t *testing.T) {
 	mockClient.AssertExpectations(t)
 
 	// Testfall: Fehler beim Überprüfen des Schreibzugriffs
+	revision("another-uid")
 	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
 		return strings.Contains(req.URL.Path, "/objects/another-uid/writable") && req.Method == http.MethodGet
 	})).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, fmt.Errorf("API error")).Once()
 
-	jobsMutex.Lock()
-	pendingJobs = append(pendingJobs, data.PendingJob{Job: data.Job{UID: "another-uid", Data: "value"}, CreatedAt: time.Now()})
-	jobsMutex.Unlock()
-
-	time.Sleep(100 * time.Millisecond)
+	done := process(data.PendingJob{ID: "another-job", Job: data.Job{UID: "another-uid", Data: "dmFsdWU="}, CreatedAt: fake.Now()})
+	// Der Job wartet auf den nächsten Versuch
+	fake.BlockUntil(1)
 
 	jobsMutex.Lock()
-	assert.Len(t, pendingJobs, 1) // Job sollte nicht entfernt worden sein
-	assert.Equal(t, "another-uid", pendingJobs[0].Job.UID)
+	if assert.Len(t, pendingJobs, 1) { // Job sollte nicht entfernt worden sein
+		assert.Equal(t, "another-uid", pendingJobs[0].Job.UID)
+		assert.Equal(t, 1, pendingJobs[0].Attempts)
+	}
+	pendingJobs = []data.PendingJob{}
 	jobsMutex.Unlock()
 	mockClient.AssertExpectations(t)
+
+	// Entfernte Jobs werden nicht weiter versucht
+	wait, _ := fake.NextWait()
+	fake.Advance(wait)
+	<-done
 }
 
 // Hilfsstruktur und Funktion für das Testen von HTTP-Aufrufen
@@ -267,11 +344,39 @@ func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return m.client.Do(req)
 }
 
-// Initialisieren Sie den httpClient mit einem Mock-Transport für Tests
-var httpClient *http.Client
+func TestJobsExportImport(t *testing.T) {
+	dir := t.TempDir()
+	defer persistence.SetCacheDir(persistence.DefaultCacheDir)
+
+	in := filepath.Join(dir, "import.json")
+	content := `[
+  {"ID": "known", "Job": {"uid": "uid-1", "data": "dmFsdWU="}},
+  {"Job": {"uid": "uid-2", "data": "dmFsdWU="}},
+  {"Job": {"data": "ohne-uid"}}
+]`
+	assert.NoError(t, os.WriteFile(in, []byte(content), 0644))
+
+	cache := filepath.Join(dir, "cache")
+	assert.NoError(t, os.Mkdir(cache, 0755))
+	assert.Equal(t, 0, run([]string{"jobs", "import", "-cache-dir", cache, in}))
+	// Erneuter Import überspringt bekannte IDs
+	assert.Equal(t, 0, run([]string{"jobs", "import", "-cache-dir", cache, in}))
+
+	out := filepath.Join(dir, "export.json")
+	t.Setenv("WAVELY_CACHE_DIR", cache)
+	assert.Equal(t, 0, run([]string{"jobs", "export", "-out", out}))
+
+	exported, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	var jobs []data.PendingJob
+	assert.NoError(t, json.Unmarshal(exported, &jobs))
+	uids := make([]string, len(jobs))
+	for i, job := range jobs {
+		uids[i] = job.Job.UID
+		assert.NotEmpty(t, job.ID)
+		assert.False(t, job.CreatedAt.IsZero())
+	}
+	assert.Equal(t, []string{"uid-1", "uid-2", "uid-2"}, uids)
 
-func init() {
-	// Verwenden Sie für Tests einen Standard-HTTP-Client, der später in den Tests gemockt werden kann
-	httpClient = &http.Client{}
-	http.DefaultClient = httpClient
+	assert.Equal(t, 2, run([]string{"unknown"}))
 }
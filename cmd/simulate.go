@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"djp.chapter42.de/a/internal/simulate"
+)
+
+// runSimulate führt "wavely simulate" aus und liefert den Exit-Code.
+func runSimulate(args []string) int {
+	opts := simulate.DefaultOptions()
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+
+	strategies := flags.String("strategy", "sinus,exponential", "kommagetrennte Strategien (sinus, exponential)")
+	flags.IntVar(&opts.Jobs, "jobs", opts.Jobs, "Anzahl der Jobs")
+	flags.StringVar(&opts.Arrival, "arrival", opts.Arrival, "Ankunftsmuster (burst, uniform, poisson)")
+	flags.DurationVar(&opts.Window, "window", opts.Window, "Zeitraum, über den die Jobs eintreffen")
+	flags.DurationVar(&opts.Horizon, "horizon", opts.Horizon, "simulierte Dauer")
+	flags.DurationVar(&opts.Bucket, "bucket", opts.Bucket, "Auflösung der Lastkurve")
+	flags.DurationVar(&opts.BaseDelay, "base-delay", opts.BaseDelay, "minimale Verzögerung")
+	flags.DurationVar(&opts.MaxDelay, "max-delay", opts.MaxDelay, "maximale Verzögerung")
+	flags.IntVar(&opts.Oscillation, "oscillation", opts.Oscillation, "Schritte je halber Sinuswelle")
+	flags.Float64Var(&opts.Jitter, "jitter", opts.Jitter, "maximaler Jitter-Faktor")
+	flags.BoolVar(&opts.Allocate, "allocate", opts.Allocate, "Phasen gleichmäßig vergeben statt zufällig")
+	flags.Uint64Var(&opts.Seed, "seed", opts.Seed, "Seed für reproduzierbare Läufe")
+	csvPath := flags.String("csv", "", "CSV-Ausgabe in Datei schreiben (- für stdout)")
+	svgPath := flags.String("svg", "", "SVG-Diagramm in Datei schreiben")
+	noChart := flags.Bool("no-chart", false, "kein ASCII-Diagramm ausgeben")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts.Strategies = simulate.ParseStrategies(*strategies)
+
+	series, err := simulate.Run(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Fehler bei der Simulation:", err)
+		return 2
+	}
+
+	if !*noChart && *csvPath != "-" {
+		simulate.WriteChart(os.Stdout, series, opts.Bucket)
+	}
+	if *csvPath != "" {
+		if err := writeOutput(*csvPath, func(w io.Writer) error { return simulate.WriteCSV(w, series, opts.Bucket) }); err != nil {
+			fmt.Fprintln(os.Stderr, "Fehler beim Schreiben der CSV-Datei:", err)
+			return 1
+		}
+	}
+	if *svgPath != "" {
+		if err := writeOutput(*svgPath, func(w io.Writer) error { return simulate.WriteSVG(w, series, opts.Bucket) }); err != nil {
+			fmt.Fprintln(os.Stderr, "Fehler beim Schreiben des SVG-Diagramms:", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const DefaultServer = "http://localhost:4224"
+
+// settings sind Endpoint und Zugangsdaten aus Konfigurationsdatei, Umgebung
+// und Flags (in aufsteigender Priorität).
+type settings struct {
+	Server   string `mapstructure:"server"`
+	Token    string `mapstructure:"token"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Output   string `mapstructure:"output"`
+}
+
+// defaultConfigFile liefert ~/.config/wavely/wavelyctl.yaml.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "wavely", "wavelyctl.yaml")
+}
+
+// loadSettings liest die Konfigurationsdatei. Eine fehlende Datei ist kein
+// Fehler, sofern sie nicht ausdrücklich angegeben wurde.
+func loadSettings(file string, explicit bool) (settings, error) {
+	v := viper.New()
+	v.SetDefault("server", DefaultServer)
+	v.SetDefault("output", outputTable)
+	v.SetEnvPrefix("WAVELYCTL")
+	for _, key := range []string{"server", "token", "username", "password", "output"} {
+		v.BindEnv(key)
+	}
+
+	if file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil && (explicit || !os.IsNotExist(err)) {
+			return settings{}, fmt.Errorf("Konfigurationsdatei nicht lesbar: %w", err)
+		}
+	}
+
+	var s settings
+	if err := v.Unmarshal(&s); err != nil {
+		return settings{}, err
+	}
+	return s, nil
+}
+
+// client spricht die HTTP-API von Wavely an.
+type client struct {
+	server   string
+	token    string
+	username string
+	password string
+	http     *http.Client
+}
+
+func newClient(s settings) *client {
+	return &client{
+		server:   strings.TrimRight(s.Server, "/"),
+		token:    s.Token,
+		username: s.Username,
+		password: s.Password,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiError ist eine Fehlerantwort der API.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+func (c *client) request(method, path string, body io.Reader, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", "wavelyctl/"+version)
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return req, nil
+}
+
+// do führt einen Aufruf aus und liefert den Body. Antworten außerhalb von 2xx
+// werden als apiError mit der Meldung aus dem Feld "error" geliefert.
+func (c *client) do(method, path string, body io.Reader, header http.Header) ([]byte, error) {
+	req, err := c.request(method, path, body, header)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(content, &failure)
+		message := failure.Error
+		if message == "" {
+			message = failure.Message
+		}
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return content, &apiError{Status: resp.StatusCode, Message: message}
+	}
+	return content, nil
+}
+
+// getJSON ruft path ab und dekodiert die Antwort nach v. Der Body wird
+// zusätzlich für die JSON-Ausgabe zurückgegeben.
+func (c *client) getJSON(path string, v any) ([]byte, error) {
+	content, err := c.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return content, err
+	}
+	return content, json.Unmarshal(content, v)
+}
+
+// stream liest Server-Sent Events und ruft fn für jedes Ereignis auf, bis die
+// Verbindung endet.
+func (c *client) stream(path string, fn func(event string, data []byte) error) error {
+	req, err := c.request(http.MethodGet, path, nil, http.Header{"Accept": {"text/event-stream"}})
+	if err != nil {
+		return err
+	}
+	// Ohne Timeout, der Stream läuft bis zum Abbruch
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &apiError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var event string
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data != nil {
+				if err := fn(event, data); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	return scanner.Err()
+}
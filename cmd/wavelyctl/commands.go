@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/poller"
+)
+
+// usageError meldet falsche Argumente; der Text ist die korrekte Verwendung.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+type command struct {
+	client *client
+	out    *printer
+	stdin  io.Reader
+}
+
+var commands = map[string]func(*command, []string) error{
+	"submit":      (*command).submit,
+	"list":        (*command).list,
+	"get":         (*command).get,
+	"cancel":      (*command).cancel,
+	"requeue":     (*command).requeue,
+	"health":      (*command).health,
+	"metrics":     (*command).metrics,
+	"events":      (*command).events,
+	"controls":    (*command).controls,
+	"pause":       targetAction("pause"),
+	"resume":      targetAction("resume"),
+	"drain":       targetAction("drain"),
+	"maintenance": (*command).maintenance,
+	"config":      (*command).config,
+	"reload":      (*command).reload,
+	"version":     (*command).version,
+}
+
+type jobEntry struct {
+	Job   data.PendingJob `json:"job"`
+	State string          `json:"state"`
+}
+
+type batchResult struct {
+	Index  int    `json:"index"`
+	UID    string `json:"uid"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Code   int    `json:"code"`
+	Error  string `json:"error"`
+}
+
+// submit reicht einen Job ein. Enthält die Eingabe ein JSON-Array oder mehrere
+// Zeilen (NDJSON), wird sie als Batch eingereicht.
+func (c *command) submit(args []string) error {
+	flags := flag.NewFlagSet("submit", flag.ContinueOnError)
+	file := flags.String("file", "-", "Datei mit Job(s) als JSON (- für stdin)")
+	key := flags.String("idempotency-key", "", "Idempotency-Key des Requests")
+	clientID := flags.String("client", "", "Client-ID für die faire Verteilung der Worker")
+	mode := flags.String("mode", "", "Batch-Modus: atomic oder best_effort")
+	if err := flags.Parse(args); err != nil {
+		return usageError("submit [-file datei] [-idempotency-key key] [-client id] [-mode modus]")
+	}
+
+	var content []byte
+	var err error
+	if *file == "-" {
+		content, err = io.ReadAll(c.stdin)
+	} else {
+		content, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return errors.New("keine Jobs in der Eingabe")
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if *key != "" {
+		header.Set("Idempotency-Key", *key)
+	}
+	if *clientID != "" {
+		header.Set("X-Client-ID", *clientID)
+	}
+
+	if content[0] != '[' && !bytes.Contains(content, []byte("\n")) {
+		body, err := c.client.do(http.MethodPost, "/jobs", bytes.NewReader(content), header)
+		if err != nil {
+			return err
+		}
+		if c.out.isJSON() {
+			return c.out.raw(body)
+		}
+		var accepted map[string]string
+		if err := json.Unmarshal(body, &accepted); err != nil {
+			return err
+		}
+		return c.out.fields("id", accepted["id"], "uid", accepted["uid"], "after", orDash(accepted["after"]), "due_at", orDash(accepted["due_at"]))
+	}
+
+	path := "/jobs/batch"
+	if *mode != "" {
+		path += "?mode=" + url.QueryEscape(*mode)
+	}
+	body, err := c.client.do(http.MethodPost, path, bytes.NewReader(content), header)
+	var apiErr *apiError
+	if err != nil && !(errors.As(err, &apiErr) && bytes.Contains(body, []byte(`"results"`))) {
+		return err
+	}
+	if c.out.isJSON() {
+		c.out.raw(body)
+		return err
+	}
+
+	var batch struct {
+		Results []batchResult `json:"results"`
+	}
+	if jsonErr := json.Unmarshal(body, &batch); jsonErr != nil {
+		return jsonErr
+	}
+	rows := make([][]string, len(batch.Results))
+	for i, r := range batch.Results {
+		rows[i] = []string{strconv.Itoa(r.Index), orDash(r.UID), orDash(r.ID), r.Status, strconv.Itoa(r.Code), orDash(r.Error)}
+	}
+	c.out.table([]string{"INDEX", "UID", "ID", "STATUS", "CODE", "ERROR"}, rows)
+	return err
+}
+
+func (c *command) list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	uid := flags.String("uid", "", "nur Jobs mit dieser UID")
+	if err := flags.Parse(args); err != nil {
+		return usageError("list [-uid uid]")
+	}
+
+	path := "/jobs"
+	if *uid != "" {
+		path += "?uid=" + url.QueryEscape(*uid)
+	}
+	var list struct {
+		Jobs []jobEntry `json:"jobs"`
+	}
+	body, err := c.client.getJSON(path, &list)
+	if err != nil {
+		return err
+	}
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+
+	rows := make([][]string, len(list.Jobs))
+	for i, entry := range list.Jobs {
+		j := entry.Job
+		rows[i] = []string{j.ID, j.Job.UID, entry.State, strconv.Itoa(j.Attempts), orDash(j.Job.Priority), orDash(j.Client), j.CreatedAt.Local().Format(time.DateTime)}
+	}
+	return c.out.table([]string{"ID", "UID", "STATE", "ATTEMPTS", "PRIORITY", "CLIENT", "CREATED"}, rows)
+}
+
+func (c *command) get(args []string) error {
+	if len(args) != 1 {
+		return usageError("get <id>")
+	}
+
+	var response struct {
+		Job           data.PendingJob `json:"job"`
+		RateLimitWait string          `json:"rate_limit_wait"`
+	}
+	body, err := c.client.getJSON("/jobs/"+url.PathEscape(args[0]), &response)
+	if err != nil {
+		return err
+	}
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+
+	j := response.Job
+	dueAt := ""
+	if due := j.Job.DueAt(); !due.IsZero() {
+		dueAt = due.Local().Format(time.DateTime)
+	}
+	return c.out.fields(
+		"id", j.ID,
+		"uid", j.Job.UID,
+		"attempts", strconv.Itoa(j.Attempts),
+		"priority", orDash(j.Job.Priority),
+		"content_type", orDash(j.Job.ContentType),
+		"client", orDash(j.Client),
+		"after", orDash(j.After),
+		"due_at", orDash(dueAt),
+		"created", j.CreatedAt.Local().Format(time.DateTime),
+		"rate_limit_wait", response.RateLimitWait,
+	)
+}
+
+func (c *command) cancel(args []string) error {
+	if len(args) != 1 {
+		return usageError("cancel <id>")
+	}
+	body, err := c.client.do(http.MethodDelete, "/jobs/"+url.PathEscape(args[0]), nil, nil)
+	if err != nil {
+		return err
+	}
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+	c.out.line("Job %s abgebrochen.", args[0])
+	return nil
+}
+
+func (c *command) requeue(args []string) error {
+	if len(args) != 1 {
+		return usageError("requeue <id>")
+	}
+	body, err := c.client.do(http.MethodPost, "/jobs/"+url.PathEscape(args[0])+"/requeue", nil, nil)
+	if err != nil {
+		return err
+	}
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+
+	var response struct {
+		Retrying bool `json:"retrying"`
+	}
+	json.Unmarshal(body, &response)
+	if response.Retrying {
+		c.out.line("Job %s wird sofort erneut versucht.", args[0])
+	} else {
+		c.out.line("Versuche von Job %s zurückgesetzt, er wartet noch in der Queue.", args[0])
+	}
+	return nil
+}
+
+func (c *command) health(args []string) error {
+	var response struct {
+		Message     string `json:"message"`
+		Maintenance bool   `json:"maintenance"`
+		Targets     map[string]struct {
+			Circuit string `json:"circuit"`
+			State   string `json:"state"`
+		} `json:"targets"`
+	}
+	body, err := c.client.getJSON("/health", &response)
+	if err != nil {
+		return err
+	}
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+
+	c.out.fields("status", response.Message, "maintenance", strconv.FormatBool(response.Maintenance))
+	var rows [][]string
+	for _, name := range sortedKeys(response.Targets) {
+		target := response.Targets[name]
+		rows = append(rows, []string{name, target.State, orDash(target.Circuit)})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	c.out.line("")
+	return c.out.table([]string{"TARGET", "STATE", "CIRCUIT"}, rows)
+}
+
+// metrics fasst /metrics zusammen: je Messreihe ein Wert, optional gefiltert
+// nach einem Präfix des Namens.
+func (c *command) metrics(args []string) error {
+	prefix := ""
+	if len(args) > 1 {
+		return usageError("metrics [präfix]")
+	} else if len(args) == 1 {
+		prefix = args[0]
+	}
+
+	body, err := c.client.do(http.MethodGet, "/metrics", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	type sample struct {
+		Name   string  `json:"name"`
+		Labels string  `json:"labels,omitempty"`
+		Value  float64 `json:"value"`
+	}
+	var samples []sample
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") || !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		series, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		name, labels, _ := strings.Cut(series, "{")
+		samples = append(samples, sample{Name: name, Labels: strings.TrimSuffix(labels, "}"), Value: v})
+	}
+
+	if c.out.isJSON() {
+		content, err := json.Marshal(samples)
+		if err != nil {
+			return err
+		}
+		return c.out.raw(content)
+	}
+	rows := make([][]string, len(samples))
+	for i, s := range samples {
+		rows[i] = []string{s.Name, orDash(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64)}
+	}
+	return c.out.table([]string{"NAME", "LABELS", "VALUE"}, rows)
+}
+
+// events verfolgt die Änderungsereignisse der Poller, bis die Verbindung endet
+// oder der Befehl abgebrochen wird.
+func (c *command) events(args []string) error {
+	return c.client.stream("/pollers/events", func(_ string, data []byte) error {
+		if c.out.isJSON() {
+			_, err := fmt.Fprintf(c.out.w, "%s\n", data)
+			return err
+		}
+		var event poller.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+		changes := ""
+		if len(event.Changes) > 0 {
+			changes = " " + strings.Join(event.Changes, ", ")
+		}
+		c.out.line("%s %s %d %s%s", event.Time.Local().Format(time.DateTime), event.Poller, event.Status, event.URL, changes)
+		return nil
+	})
+}
+
+func (c *command) controls(args []string) error {
+	body, err := c.client.do(http.MethodGet, "/admin/controls", nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.printControls(body)
+}
+
+func targetAction(action string) func(*command, []string) error {
+	return func(c *command, args []string) error {
+		if len(args) != 1 {
+			return usageError(action + " <target>")
+		}
+		body, err := c.client.do(http.MethodPost, "/admin/targets/"+url.PathEscape(args[0])+"/"+action, nil, nil)
+		if err != nil {
+			return err
+		}
+		return c.printControls(body)
+	}
+}
+
+func (c *command) maintenance(args []string) error {
+	method := ""
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			method = http.MethodPost
+		case "off":
+			method = http.MethodDelete
+		}
+	}
+	if method == "" {
+		return usageError("maintenance on|off")
+	}
+	body, err := c.client.do(method, "/admin/maintenance", nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.printControls(body)
+}
+
+func (c *command) printControls(body []byte) error {
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+
+	var status struct {
+		Maintenance bool `json:"maintenance"`
+		Targets     map[string]struct {
+			State    string `json:"state"`
+			InFlight int    `json:"in_flight"`
+		} `json:"targets"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return err
+	}
+	c.out.fields("maintenance", strconv.FormatBool(status.Maintenance))
+	c.out.line("")
+	var rows [][]string
+	for _, name := range sortedKeys(status.Targets) {
+		target := status.Targets[name]
+		rows = append(rows, []string{name, target.State, strconv.Itoa(target.InFlight)})
+	}
+	return c.out.table([]string{"TARGET", "STATE", "IN_FLIGHT"}, rows)
+}
+
+func (c *command) config(args []string) error {
+	body, err := c.client.do(http.MethodGet, "/admin/config", nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.printConfig(body)
+}
+
+func (c *command) reload(args []string) error {
+	body, err := c.client.do(http.MethodPost, "/admin/config/reload", nil, nil)
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && body != nil {
+			c.printConfig(body)
+		}
+		return err
+	}
+	return c.printConfig(body)
+}
+
+func (c *command) printConfig(body []byte) error {
+	if c.out.isJSON() {
+		return c.out.raw(body)
+	}
+
+	type reloadStatus struct {
+		File      string    `json:"file"`
+		Hash      string    `json:"hash"`
+		LoadedAt  time.Time `json:"loaded_at"`
+		Reloads   int       `json:"reloads"`
+		LastError string    `json:"last_error"`
+	}
+	type configStatus struct {
+		Config  reloadStatus `json:"config"`
+		Workers int          `json:"workers"`
+	}
+	var response struct {
+		configStatus
+		Status configStatus `json:"status"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	// Bei einem fehlgeschlagenen Reload steckt der Status in "status"
+	status := response.configStatus
+	if status.Config.Hash == "" {
+		status = response.Status
+	}
+	cfg := status.Config
+	return c.out.fields(
+		"file", orDash(cfg.File),
+		"hash", orDash(cfg.Hash),
+		"loaded_at", cfg.LoadedAt.Local().Format(time.DateTime),
+		"reloads", strconv.Itoa(cfg.Reloads),
+		"workers", strconv.Itoa(status.Workers),
+		"last_error", orDash(cfg.LastError),
+	)
+}
+
+func (c *command) version(args []string) error {
+	c.out.line("wavelyctl %s", version)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
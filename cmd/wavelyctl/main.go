@@ -0,0 +1,106 @@
+// wavelyctl ist der Kommandozeilen-Client für die HTTP-API von Wavely.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// version wird beim Bauen gesetzt: go build -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+const usage = `Verwendung: wavelyctl [flags] <befehl> [argumente]
+
+Jobs:
+  submit [-file datei]     Job(s) aus Datei oder stdin einreichen (Array/NDJSON als Batch)
+  list [-uid uid]          ausstehende Jobs auflisten
+  get <id>                 Job anzeigen
+  cancel <id>              Job abbrechen
+  requeue <id>             Versuche zurücksetzen und sofort erneut versuchen
+
+Betrieb:
+  health                   Zustand von Server und Targets
+  metrics [präfix]         Metriken zusammenfassen
+  events                   Änderungsereignisse der Poller verfolgen
+  controls                 Betriebszustand der Targets
+  pause|resume|drain <target>
+  maintenance on|off
+  config                   Status der aktiven Konfiguration
+  reload                   Konfiguration neu laden
+  version                  Version ausgeben
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run führt einen Befehl aus und liefert den Exit-Code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("wavelyctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	configFile := flags.String("config", envOr("WAVELYCTL_CONFIG", defaultConfigFile()), "Konfigurationsdatei mit server, token bzw. username/password [WAVELYCTL_CONFIG]")
+	server := flags.String("server", "", "Adresse des Servers (Standard: "+DefaultServer+") [WAVELYCTL_SERVER]")
+	output := flags.String("o", "", "Ausgabeformat: table oder json [WAVELYCTL_OUTPUT]")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	explicit := false
+	flags.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+	s, err := loadSettings(*configFile, explicit || os.Getenv("WAVELYCTL_CONFIG") != "")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *server != "" {
+		s.Server = *server
+	}
+	if *output != "" {
+		s.Output = *output
+	}
+	if s.Output != outputTable && s.Output != outputJSON {
+		fmt.Fprintln(stderr, "Unbekanntes Ausgabeformat:", s.Output)
+		return 2
+	}
+
+	cmd := &command{client: newClient(s), out: newPrinter(stdout, s.Output), stdin: stdin}
+	name, rest := flags.Arg(0), flags.Args()[1:]
+
+	handler, ok := commands[name]
+	if !ok {
+		fmt.Fprintln(stderr, "Unbekannter Befehl:", name)
+		flags.Usage()
+		return 2
+	}
+	if err := handler(cmd, rest); err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintln(stderr, "Verwendung: wavelyctl", string(usageErr))
+			return 2
+		}
+		fmt.Fprintln(stderr, "Fehler:", err)
+		return 1
+	}
+	return 0
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
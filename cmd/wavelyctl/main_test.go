@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/handlers"
+	"djp.chapter42.de/a/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWavelyctl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+
+	var mu sync.Mutex
+	var pending []data.PendingJob
+	var authorization string
+	router := gin.New()
+	router.Use(func(c *gin.Context) { authorization = c.GetHeader("Authorization") })
+	router.POST("/jobs", handlers.NewJobHandler(&mu, &pending))
+	router.POST("/jobs/batch", handlers.NewBatchJobHandler(&mu, &pending))
+	router.GET("/jobs", handlers.ListJobsHandler(&mu, &pending))
+	router.GET("/jobs/:id", handlers.GetJobHandler(&mu, &pending))
+	router.DELETE("/jobs/:id", handlers.CancelJobHandler(&mu, &pending))
+	router.POST("/jobs/:id/requeue", handlers.RequeueJobHandler(&mu, &pending))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Endpoint und Zugangsdaten aus der Konfigurationsdatei
+	configFile := filepath.Join(t.TempDir(), "wavelyctl.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("server: "+server.URL+"\ntoken: secret\n"), 0600))
+
+	ctl := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"-config", configFile}, args...), strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, _ := ctl(`{"uid": "uid-1", "data": "dmFsdWU="}`, "submit")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "uid-1")
+	assert.Equal(t, "Bearer secret", authorization)
+
+	code, out, _ = ctl("{\"uid\": \"uid-2\", \"data\": \"dmFsdWU=\"}\n{\"uid\": \"uid-3\"}", "submit")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "accepted")
+	assert.Contains(t, out, "data fehlt")
+
+	code, out, _ = ctl("", "-o", "json", "list")
+	assert.Equal(t, 0, code)
+	var list struct {
+		Jobs []jobEntry `json:"jobs"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &list))
+	assert.Len(t, list.Jobs, 2)
+
+	code, out, _ = ctl("", "list", "-uid", "uid-2")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "uid-2")
+	assert.NotContains(t, out, "uid-1")
+
+	id := list.Jobs[0].Job.ID
+	code, out, _ = ctl("", "requeue", id)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "zurückgesetzt")
+
+	code, _, _ = ctl("", "cancel", id)
+	assert.Equal(t, 0, code)
+	code, _, errOut := ctl("", "get", id)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "Job nicht gefunden (HTTP 404)")
+
+	code, _, errOut = ctl("", "cancel")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "cancel <id>")
+}
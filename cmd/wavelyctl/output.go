@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer gibt Antworten als Tabelle oder als unveränderte JSON-Antwort aus.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, format: format}
+}
+
+func (p *printer) isJSON() bool {
+	return p.format == outputJSON
+}
+
+// raw gibt eine JSON-Antwort eingerückt aus.
+func (p *printer) raw(content []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, content, "", "  "); err != nil {
+		_, err = p.w.Write(content)
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(p.w)
+	return err
+}
+
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// fields gibt Name-Wert-Paare untereinander aus.
+func (p *printer) fields(pairs ...string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fmt.Fprintf(tw, "%s:\t%s\n", pairs[i], pairs[i+1])
+	}
+	return tw.Flush()
+}
+
+func (p *printer) line(format string, args ...any) {
+	fmt.Fprintf(p.w, format+"\n", args...)
+}
+
+// orDash ersetzt leere Werte in Tabellen durch "-".
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
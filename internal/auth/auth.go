@@ -11,6 +11,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"djp.chapter42.de/a/internal/clock"
 )
 
 type AuthConfig struct {
@@ -51,6 +53,8 @@ type OAuth2Auth struct {
 	ClientSecret string
 	TokenURL     string
 	RefreshToken string
+	// Uhr für den Ablauf des Tokens (nil: Systemuhr)
+	Clock clock.Clock
 
 	accessToken string
 	expiresAt   time.Time
@@ -61,7 +65,7 @@ func (o *OAuth2Auth) GetAuthHeader() (string, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	if time.Now().Before(o.expiresAt) && o.accessToken != "" {
+	if clock.Or(o.Clock).Now().Before(o.expiresAt) && o.accessToken != "" {
 		return "Bearer " + o.accessToken, nil
 	}
 	return o.refreshAccessToken()
@@ -94,7 +98,7 @@ func (o *OAuth2Auth) refreshAccessToken() (string, error) {
 	}
 
 	o.accessToken = tokenResp.AccessToken
-	o.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn-10) * time.Second)
+	o.expiresAt = clock.Or(o.Clock).Now().Add(time.Duration(tokenResp.ExpiresIn-10) * time.Second)
 
 	return "Bearer " + o.accessToken, nil
 }
@@ -0,0 +1,221 @@
+package blackout
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	_ "time/tzdata" // Zeitzonen auch ohne tzdata im Container
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultReleaseSpread = 5 * time.Minute
+	dateLayout           = "2006-01-02"
+	// Schutz gegen Endlosschleifen bei lückenlos aneinandergereihten Fenstern
+	maxChainedWindows = 64
+)
+
+type Config struct {
+	Timezone string         `mapstructure:"timezone"`
+	Windows  []WindowConfig `mapstructure:"windows"`
+	// Ganztägige Sperrtage
+	Dates []string `mapstructure:"dates"`
+	// Tage, an denen die Fenster nicht gelten (z.B. Feiertage ohne Produktion)
+	Exceptions []string `mapstructure:"exceptions"`
+	// Zeitraum, über den die Jobs nach Ende eines Fensters gestreut werden
+	ReleaseSpread time.Duration `mapstructure:"release_spread"`
+}
+
+type WindowConfig struct {
+	Days  []string `mapstructure:"days"` // mon, tue, ...; leer = täglich
+	Start string   `mapstructure:"start"`
+	End   string   `mapstructure:"end"`
+}
+
+// Calendar beantwortet, ob ein Target zu einem Zeitpunkt gesperrt ist.
+type Calendar struct {
+	loc        *time.Location
+	windows    []window
+	dates      map[string]bool
+	exceptions map[string]bool
+	release    time.Duration
+	// Uhr für das Warten auf das Fensterende (nil: Systemuhr)
+	Clock clock.Clock
+}
+
+type window struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration // seit Mitternacht
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// BuildCalendar liefert nil, wenn keine Sperrzeiten konfiguriert sind.
+func BuildCalendar(cfg Config) (*Calendar, error) {
+	if len(cfg.Windows) == 0 && len(cfg.Dates) == 0 {
+		return nil, nil
+	}
+
+	loc := time.Local
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("unbekannte Zeitzone %q: %w", cfg.Timezone, err)
+		}
+	}
+
+	c := &Calendar{loc: loc, dates: make(map[string]bool), exceptions: make(map[string]bool), release: cfg.ReleaseSpread}
+	if c.release <= 0 {
+		c.release = DefaultReleaseSpread
+	}
+
+	for i, wc := range cfg.Windows {
+		w := window{}
+		var err error
+		if w.start, err = parseClock(wc.Start); err != nil {
+			return nil, fmt.Errorf("windows[%d].start: %w", i, err)
+		}
+		if w.end, err = parseClock(wc.End); err != nil {
+			return nil, fmt.Errorf("windows[%d].end: %w", i, err)
+		}
+		if len(wc.Days) > 0 {
+			w.days = make(map[time.Weekday]bool)
+			for _, d := range wc.Days {
+				// Die ersten drei Zeichen, nicht Bytes: Kleinschreibung kann die Länge ändern
+				name := []rune(strings.ToLower(d))
+				day, ok := weekdays[string(name[:min(3, len(name))])]
+				if !ok {
+					return nil, fmt.Errorf("windows[%d].days: unbekannter Wochentag %q", i, d)
+				}
+				w.days[day] = true
+			}
+		}
+		c.windows = append(c.windows, w)
+	}
+
+	for i, d := range cfg.Dates {
+		if _, err := time.ParseInLocation(dateLayout, d, loc); err != nil {
+			return nil, fmt.Errorf("dates[%d]: %w", i, err)
+		}
+		c.dates[d] = true
+	}
+	for i, d := range cfg.Exceptions {
+		if _, err := time.ParseInLocation(dateLayout, d, loc); err != nil {
+			return nil, fmt.Errorf("exceptions[%d]: %w", i, err)
+		}
+		c.exceptions[d] = true
+	}
+	return c, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("ungültige Uhrzeit %q (HH:MM)", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Until liefert das Ende der Sperrzeit, falls t in eine fällt. Direkt
+// aneinandergrenzende Fenster werden zusammengefasst.
+func (c *Calendar) Until(t time.Time) (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+
+	end, active := c.activeUntil(t)
+	if !active {
+		return time.Time{}, false
+	}
+	for i := 0; i < maxChainedWindows; i++ {
+		next, ok := c.activeUntil(end)
+		if !ok || !next.After(end) {
+			break
+		}
+		end = next
+	}
+	return end, true
+}
+
+func (c *Calendar) activeUntil(t time.Time) (time.Time, bool) {
+	lt := t.In(c.loc)
+	midnight := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, c.loc)
+	date := lt.Format(dateLayout)
+
+	if c.dates[date] {
+		return midnight.AddDate(0, 0, 1), true
+	}
+
+	var end time.Time
+	active := false
+	// Fenster von heute und über Mitternacht reichende Fenster von gestern
+	for _, day := range []time.Time{midnight, midnight.AddDate(0, 0, -1)} {
+		if c.exceptions[day.Format(dateLayout)] {
+			continue
+		}
+		for _, w := range c.windows {
+			if w.days != nil && !w.days[day.Weekday()] {
+				continue
+			}
+			start := at(day, 0, w.start)
+			stop := at(day, 0, w.end)
+			if w.end <= w.start {
+				stop = at(day, 1, w.end)
+			}
+			if !lt.Before(start) && lt.Before(stop) && stop.After(end) {
+				end = stop
+				active = true
+			}
+		}
+	}
+	return end, active
+}
+
+// at liefert die Uhrzeit since (seit Mitternacht) days Tage nach day. Die Zeit
+// wird über time.Date gebildet, damit sie auch an Tagen mit Zeitumstellung stimmt.
+func at(day time.Time, days int, since time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+days, int(since/time.Hour), int(since%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// releaseOffset bildet die Phase linear auf [0, release) ab. Gleichmäßig
+// verteilte Phasen ergeben so gleichmäßig verteilte Freigaben.
+func releaseOffset(phase float64, release time.Duration) time.Duration {
+	phase = math.Mod(phase, 2*math.Pi)
+	if phase < 0 {
+		phase += 2 * math.Pi
+	}
+	return time.Duration(phase / (2 * math.Pi) * float64(release))
+}
+
+// Wait blockiert, solange die aktuelle Zeit in eine Sperrzeit fällt, und gibt den
+// Job danach abhängig von seiner Phase verteilt über ReleaseSpread frei. Liefert
+// true, wenn bis zum Ende gewartet wurde, und false, wenn keine Sperrzeit aktiv
+// ist oder ctx das Warten vorher beendet.
+func (c *Calendar) Wait(ctx context.Context, uid string, phase float64) bool {
+	if c == nil {
+		return false
+	}
+
+	clk := clock.Or(c.Clock)
+	end, active := c.Until(clk.Now())
+	if !active {
+		return false
+	}
+
+	offset := releaseOffset(phase, c.release)
+	logger.Log.Info("Target in Sperrzeit, Versuch wird verschoben:", zap.String("uid", uid), zap.Time("until", end), zap.Duration("offset", offset))
+	select {
+	case <-clk.After(clock.Until(clk, end) + offset):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
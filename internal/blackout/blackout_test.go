@@ -0,0 +1,126 @@
+package blackout_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/blackout"
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestBlackoutCalendar(t *testing.T) {
+	calendar, err := blackout.BuildCalendar(blackout.Config{
+		Timezone:   "UTC",
+		Windows:    []blackout.WindowConfig{{Start: "22:00", End: "06:00"}},
+		Dates:      []string{"2025-05-20"},
+		Exceptions: []string{"2025-05-18"},
+	})
+	assert.NoError(t, err)
+
+	// Fenster über Mitternacht
+	until, active := calendar.Until(time.Date(2025, 5, 17, 3, 0, 0, 0, time.UTC))
+	assert.True(t, active)
+	assert.Equal(t, time.Date(2025, 5, 17, 6, 0, 0, 0, time.UTC), until)
+
+	_, active = calendar.Until(time.Date(2025, 5, 17, 12, 0, 0, 0, time.UTC))
+	assert.False(t, active)
+
+	// Ausnahmetag: das am Abend beginnende Fenster gilt nicht
+	_, active = calendar.Until(time.Date(2025, 5, 18, 23, 0, 0, 0, time.UTC))
+	assert.False(t, active)
+
+	// Sperrtag schließt nahtlos an das nächtliche Fenster an
+	until, active = calendar.Until(time.Date(2025, 5, 19, 23, 0, 0, 0, time.UTC))
+	assert.True(t, active)
+	assert.Equal(t, time.Date(2025, 5, 21, 6, 0, 0, 0, time.UTC), until)
+}
+
+func TestBlackoutDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	calendar, err := blackout.BuildCalendar(blackout.Config{
+		Timezone: "Europe/Berlin",
+		Windows:  []blackout.WindowConfig{{Start: "01:00", End: "04:00"}, {Days: []string{"SUNDAY"}, Start: "22:00", End: "23:00"}},
+	})
+	assert.NoError(t, err)
+
+	// Sommerzeit beginnt um 02:00, das Fenster endet trotzdem um 04:00 Ortszeit
+	until, active := calendar.Until(time.Date(2025, 3, 30, 3, 30, 0, 0, berlin))
+	assert.True(t, active)
+	assert.Equal(t, time.Date(2025, 3, 30, 4, 0, 0, 0, berlin), until)
+	_, active = calendar.Until(time.Date(2025, 3, 30, 4, 30, 0, 0, berlin))
+	assert.False(t, active)
+
+	until, active = calendar.Until(time.Date(2025, 10, 26, 22, 30, 0, 0, berlin))
+	assert.True(t, active)
+	assert.Equal(t, time.Date(2025, 10, 26, 23, 0, 0, 0, berlin), until)
+
+	// Wochentage mit Zeichen, deren Kleinschreibung länger oder kürzer ist
+	for _, day := range []string{"ẞ", "İ", "Ⅻ"} {
+		_, err = blackout.BuildCalendar(blackout.Config{Windows: []blackout.WindowConfig{{Days: []string{day}, Start: "01:00", End: "02:00"}}})
+		assert.Error(t, err, day)
+	}
+}
+
+func TestBlackoutWaitCancelled(t *testing.T) {
+	logger.Log = zap.NewNop()
+	calendar, err := blackout.BuildCalendar(blackout.Config{
+		Timezone: "UTC",
+		Windows:  []blackout.WindowConfig{{Start: "22:00", End: "06:00"}},
+	})
+	assert.NoError(t, err)
+	fake := clock.NewFake(time.Date(2025, 5, 17, 3, 0, 0, 0, time.UTC))
+	calendar.Clock = fake
+
+	// Außerhalb der Sperrzeit wird nicht gewartet
+	assert.False(t, (*blackout.Calendar)(nil).Wait(context.Background(), "uid", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan bool)
+	go func() { waited <- calendar.Wait(ctx, "uid", 0) }()
+	fake.BlockUntil(1)
+	cancel()
+	assert.False(t, <-waited)
+
+	// Bis zum Ende der Sperrzeit, bei Phase 0 ohne Versatz
+	go func() { waited <- calendar.Wait(context.Background(), "uid", 0) }()
+	fake.BlockUntil(2)
+	wait, _ := fake.NextWait()
+	assert.Equal(t, 3*time.Hour, wait)
+	fake.Advance(wait)
+	assert.True(t, <-waited)
+}
+
+func TestBlackoutReleaseSpread(t *testing.T) {
+	logger.Log = zap.NewNop()
+	calendar, err := blackout.BuildCalendar(blackout.Config{
+		Timezone:      "UTC",
+		Windows:       []blackout.WindowConfig{{Start: "22:00", End: "06:00"}},
+		ReleaseSpread: 8 * time.Minute,
+	})
+	assert.NoError(t, err)
+
+	// Gleichmäßig verteilte Phasen werden einzeln und gleichmäßig freigegeben,
+	// auch symmetrische Phasen wie π/4 und 7π/4
+	var offsets []time.Duration
+	for i := range 8 {
+		fake := clock.NewFake(time.Date(2025, 5, 17, 3, 0, 0, 0, time.UTC))
+		calendar.Clock = fake
+		ctx, cancel := context.WithCancel(context.Background())
+		waited := make(chan bool)
+		go func() { waited <- calendar.Wait(ctx, "uid", float64(i)*math.Pi/4) }()
+		fake.BlockUntil(1)
+		wait, _ := fake.NextWait()
+		offsets = append(offsets, wait-3*time.Hour)
+		cancel()
+		<-waited
+	}
+	for i, offset := range offsets {
+		assert.Equal(t, time.Duration(i)*time.Minute, offset)
+	}
+}
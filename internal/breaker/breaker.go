@@ -0,0 +1,209 @@
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+
+	DefaultWindow      = 20
+	DefaultMinRequests = 10
+	DefaultOpenTimeout = 30 * time.Second
+)
+
+type Config struct {
+	// Öffnet nach so vielen Fehlern in Folge (0 = deaktiviert)
+	ConsecutiveFailures int `mapstructure:"consecutive_failures"`
+	// Öffnet, wenn die Fehlerquote der letzten Window Aufrufe diesen Wert erreicht (0 = deaktiviert)
+	ErrorRate   float64       `mapstructure:"error_rate"`
+	Window      int           `mapstructure:"window"`
+	MinRequests int           `mapstructure:"min_requests"`
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+}
+
+// Breaker schützt ein Target: ist er offen, werden alle Jobs geparkt, bis im
+// halboffenen Zustand eine einzelne Probe-Anfrage erfolgreich war.
+type Breaker struct {
+	mu     sync.Mutex
+	target string
+	cfg    Config
+	// Uhr für Timeouts und Parken (nil: Systemuhr)
+	Clock clock.Clock
+
+	state       string
+	consecutive int
+	outcomes    []bool // Ringpuffer, true = Fehler
+	next        int
+	filled      int
+	openedAt    time.Time
+	probeAt     time.Time
+}
+
+// Build liefert nil, wenn keine Schwelle konfiguriert ist.
+func Build(target string, cfg Config) *Breaker {
+	if cfg.ConsecutiveFailures <= 0 && cfg.ErrorRate <= 0 {
+		return nil
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = min(DefaultMinRequests, cfg.Window)
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultOpenTimeout
+	}
+
+	metrics.Describe("wavely_circuit_state", "Circuit breaker state per target (0 closed, 1 half-open, 2 open).")
+	metrics.Describe("wavely_circuit_transitions_total", "Circuit breaker state transitions per target.")
+
+	b := &Breaker{target: target, cfg: cfg, state: StateClosed, outcomes: make([]bool, cfg.Window)}
+	b.publish()
+	return b
+}
+
+// Allow prüft, ob eine Anfrage gesendet werden darf. Im halboffenen Zustand wird
+// genau eine Probe durchgelassen; ansonsten wird die Wartezeit bis zur nächsten
+// Prüfung geliefert.
+func (b *Breaker) Allow() (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := clock.Or(b.Clock).Now()
+	switch b.state {
+	case StateOpen:
+		reopen := b.openedAt.Add(b.cfg.OpenTimeout)
+		if now.Before(reopen) {
+			return false, reopen.Sub(now)
+		}
+		b.transition(StateHalfOpen)
+		b.probeAt = now
+		return true, 0
+	case StateHalfOpen:
+		// Eine Probe, die kein Ergebnis liefert, blockiert nicht dauerhaft
+		if now.Sub(b.probeAt) >= b.cfg.OpenTimeout {
+			b.probeAt = now
+			return true, 0
+		}
+		return false, b.probeAt.Add(b.cfg.OpenTimeout).Sub(now)
+	default:
+		return true, 0
+	}
+}
+
+// Wait parkt den Aufrufer, solange der Breaker keine Anfrage zulässt. Liefert
+// false, wenn ctx vorher endet.
+func (b *Breaker) Wait(ctx context.Context, uid string) bool {
+	for {
+		ok, wait := b.Allow()
+		if ok {
+			return true
+		}
+		logger.Log.Debug("Circuit Breaker offen, Job geparkt:", zap.String("uid", uid), zap.Duration("wait", wait))
+		select {
+		case <-clock.Or(b.Clock).After(wait):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Record verbucht das Ergebnis eines Aufrufs gegen das Target.
+func (b *Breaker) Record(failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.outcomes[b.next] = failed
+	b.next = (b.next + 1) % len(b.outcomes)
+	if b.filled < len(b.outcomes) {
+		b.filled++
+	}
+
+	if !failed {
+		b.consecutive = 0
+		if b.state == StateHalfOpen {
+			b.reset()
+			b.transition(StateClosed)
+		}
+		return
+	}
+
+	b.consecutive++
+	if b.state == StateHalfOpen || b.tripped() {
+		b.openedAt = clock.Or(b.Clock).Now()
+		if b.state != StateOpen {
+			logger.Log.Warn("Circuit Breaker geöffnet:", zap.String("target", b.target), zap.Int("consecutive_failures", b.consecutive))
+			b.transition(StateOpen)
+		}
+	}
+}
+
+func (b *Breaker) tripped() bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutive >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+	if b.cfg.ErrorRate <= 0 || b.filled < b.cfg.MinRequests {
+		return false
+	}
+
+	failures := 0
+	for i := 0; i < b.filled; i++ {
+		if b.outcomes[i] {
+			failures++
+		}
+	}
+	return float64(failures)/float64(b.filled) >= b.cfg.ErrorRate
+}
+
+func (b *Breaker) reset() {
+	b.consecutive = 0
+	b.filled = 0
+	b.next = 0
+}
+
+func (b *Breaker) State() string {
+	if b == nil {
+		return StateClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) transition(state string) {
+	if b.state != state {
+		logger.Log.Info("Circuit Breaker Zustandswechsel:", zap.String("target", b.target), zap.String("from", b.state), zap.String("to", state))
+		metrics.Add("wavely_circuit_transitions_total", 1, "target", b.target, "to", state)
+	}
+	b.state = state
+	b.publish()
+}
+
+func (b *Breaker) publish() {
+	value := 0.0
+	switch b.state {
+	case StateHalfOpen:
+		value = 1
+	case StateOpen:
+		value = 2
+	}
+	metrics.Set("wavely_circuit_state", value, "target", b.target)
+}
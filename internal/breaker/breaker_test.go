@@ -0,0 +1,73 @@
+package breaker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/breaker"
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func initTestLogger() *zap.Logger {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	logger, _ := cfg.Build()
+	return logger
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	logger.Log = initTestLogger()
+	cb := breaker.Build("test-target", breaker.Config{ConsecutiveFailures: 2, OpenTimeout: 20 * time.Millisecond})
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	cb.Clock = fake
+
+	cb.Record(true)
+	assert.Equal(t, breaker.StateClosed, cb.State())
+	cb.Record(true)
+	assert.Equal(t, breaker.StateOpen, cb.State())
+
+	ok, wait := cb.Allow()
+	assert.False(t, ok)
+	assert.True(t, wait > 0)
+
+	// Nach Ablauf genau eine Probe im halboffenen Zustand
+	fake.Advance(25 * time.Millisecond)
+	ok, _ = cb.Allow()
+	assert.True(t, ok)
+	assert.Equal(t, breaker.StateHalfOpen, cb.State())
+	ok, _ = cb.Allow()
+	assert.False(t, ok)
+
+	cb.Record(false)
+	assert.Equal(t, breaker.StateClosed, cb.State())
+}
+
+func TestCircuitBreakerWait(t *testing.T) {
+	logger.Log = initTestLogger()
+	assert.True(t, (*breaker.Breaker)(nil).Wait(context.Background(), "uid"))
+
+	cb := breaker.Build("test-target", breaker.Config{ConsecutiveFailures: 1, OpenTimeout: time.Minute})
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	cb.Clock = fake
+	cb.Record(true)
+
+	// Ein geparkter Job kehrt zurück, sobald sein Kontext endet
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan bool, 1)
+	go func() { waited <- cb.Wait(ctx, "uid") }()
+	fake.BlockUntil(1)
+	cancel()
+	assert.False(t, <-waited)
+	assert.Equal(t, breaker.StateOpen, cb.State())
+
+	// Ohne Abbruch wartet er bis zur Probe im halboffenen Zustand
+	go func() { waited <- cb.Wait(context.Background(), "uid") }()
+	fake.BlockUntil(2)
+	fake.Advance(time.Minute)
+	assert.True(t, <-waited)
+	assert.Equal(t, breaker.StateHalfOpen, cb.State())
+}
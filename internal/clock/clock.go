@@ -0,0 +1,36 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock kapselt alle Zugriffe auf die Uhr, damit Wartezeiten in Tests ohne
+// echtes Warten vorgespult werden können.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real ist die Systemuhr.
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Default wird verwendet, wenn keine Uhr injiziert wurde.
+var Default Clock = Real{}
+
+// Or liefert c oder, falls keine Uhr gesetzt ist, die Standarduhr.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Default
+	}
+	return c
+}
+
+// Until liefert die Zeit bis t gemäß der Uhr c.
+func Until(c Clock, t time.Time) time.Duration {
+	return t.Sub(Or(c).Now())
+}
@@ -0,0 +1,103 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake ist eine manuell gesteuerte Uhr für Tests. Sleep und After kehren erst
+// zurück, wenn die Zeit per Advance über ihren Zeitpunkt hinaus vorgespult wird.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, waiter{until: f.now.Add(d), ch: ch})
+	f.cond.Broadcast()
+	return ch
+}
+
+// Advance spult die Uhr vor und weckt alle Wartenden, deren Zeitpunkt erreicht ist.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	remaining := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.until.After(f.now) {
+			remaining = append(remaining, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = remaining
+}
+
+// Waiters liefert die Anzahl der aktuell wartenden Aufrufe.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil wartet, bis mindestens n Aufrufe auf die Uhr warten. Damit kann ein
+// Test sicher vorspulen, ohne dass ein Sleep erst danach beginnt.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// NextWait liefert die Zeit bis zum frühesten Wartenden.
+func (f *Fake) NextWait() (time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.waiters) == 0 {
+		return 0, false
+	}
+	next := f.waiters[0].until
+	for _, w := range f.waiters[1:] {
+		if w.until.Before(next) {
+			next = w.until
+		}
+	}
+	return next.Sub(f.now), true
+}
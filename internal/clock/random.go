@@ -0,0 +1,30 @@
+package clock
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// Random ist eine threadsichere Zufallsquelle mit festem Seed, damit Phasen und
+// Jitter reproduzierbar sind. Eine nil-Quelle greift auf math/rand/v2 zurück.
+type Random struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// NewRandom erzeugt eine Zufallsquelle. Mit seed 0 wird zufällig geseedet.
+func NewRandom(seed uint64) *Random {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Random{r: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (r *Random) Float64() float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Float64()
+}
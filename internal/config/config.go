@@ -1,48 +1,171 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"reflect"
 
 	"djp.chapter42.de/a/internal/auth"
+	"djp.chapter42.de/a/internal/blackout"
+	"djp.chapter42.de/a/internal/breaker"
+	"djp.chapter42.de/a/internal/clock"
 	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/ratelimit"
 	"djp.chapter42.de/a/internal/tmpl"
 	"github.com/spf13/viper"
-	"go.uber.org/zap"
 )
 
 const (
 	DefaultPort string = "4224"
+	// Anzahl der Worker, sofern max_workers nicht gesetzt ist
+	DefaultMaxWorkers int = 10
 )
 
+// Config ist die beim Start geladene Konfiguration. Nach einem Reload liefert
+// Active die jeweils gültige.
 var Config *data.WavelyConfig
 
-func InitConfig(logger *zap.Logger) {
+// ConfigFile überschreibt die Suche nach wavely.cfg.yaml in /app/config.
+var ConfigFile string
+
+// Port überschreibt den Port aus der Konfigurationsdatei.
+var Port string
+
+// InitConfig lädt und prüft die Konfiguration. Bei einem Fehler wird nicht
+// gestartet; ValidationError enthält dann alle fehlerhaften Felder.
+func InitConfig() error {
+	v := newViper()
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("Konfigurationsdatei nicht lesbar: %w", err)
+	}
+
+	cfg, err := build(v, nil)
+	if err != nil {
+		return err
+	}
+	Config = cfg
+	activate(cfg, v.ConfigFileUsed())
+	return nil
+}
+
+// Check prüft eine Konfigurationsdatei, ohne sie zu übernehmen.
+func Check(file string) error {
+	v := newViper()
+	if file != "" {
+		v.SetConfigFile(file)
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("Konfigurationsdatei nicht lesbar: %w", err)
+	}
+	_, err := build(v, nil)
+	return err
+}
+
+func newViper() *viper.Viper {
 	v := viper.New()
 	v.SetDefault("port", DefaultPort)
+	v.SetDefault("shutdown.grace_period", "30s")
+	v.SetDefault("current.duplicate_policy", data.DuplicateQueue)
+	v.SetDefault("current.idempotency.window", "24h")
+	v.SetDefault("current.batch.mode", data.BatchBestEffort)
+	v.SetDefault("current.batch.max_items", 1000)
+	v.SetDefault("current.restore.rate", 10)
+	v.SetDefault("current.max_workers", DefaultMaxWorkers)
+	v.SetDefault("current.verify.algorithm", data.ChecksumSHA256)
+	v.SetDefault("current.verify.attempts", 3)
+	v.SetDefault("current.verify.interval", "2s")
+	if Port != "" {
+		v.Set("port", Port)
+	}
+	if ConfigFile != "" {
+		v.SetConfigFile(ConfigFile)
+		return v
+	}
 	v.SetConfigName("wavely.cfg")
 	v.SetConfigType("yaml")
 	// v.AddConfigPath("./config")
 	v.AddConfigPath("/app/config")
-	err := v.ReadInConfig()
-	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			logger.Warn("Konfigurationsdatei nicht gefunden, verwende Standardwerte")
-		} else {
-			logger.Error("Fehler beim Lesen der Konfigurationsdatei:", zap.Error(err))
+	return v
+}
+
+// build erzeugt aus den gelesenen Werten eine vollständige Konfiguration samt
+// Templates, AuthProvider, Kalender, Limiter und Breaker. Unveränderte Teile
+// werden aus previous übernommen, damit OAuth-Token, offene Circuits und
+// Token-Buckets einen Reload überstehen.
+func build(v *viper.Viper, previous *data.WavelyConfig) (*data.WavelyConfig, error) {
+	var cfg *data.WavelyConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("ungültige Konfiguration: %w", err)
+	}
+	if err := validate(v.AllSettings(), cfg); err != nil {
+		return nil, err
+	}
+	if err := tmpl.PrepareTemplates(cfg); err != nil {
+		return nil, err
+	}
+
+	current := &cfg.Current
+	prev := &data.CurrentConfig{}
+	if previous != nil {
+		prev = &previous.Current
+	}
+	sameTarget := previous != nil && prev.Name == current.Name
+
+	if sameTarget && reflect.DeepEqual(prev.Auth, current.Auth) {
+		current.AuthProvider = prev.AuthProvider
+	} else {
+		provider, err := auth.BuildAuthProvider(current.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("AuthProvider für %s: %w", current.Name, err)
 		}
+		current.AuthProvider = provider
 	}
 
-	if err := v.Unmarshal(&Config); err != nil {
-		logger.Error("Fehler beim Lesen der Konfigurationsdatei:", zap.Error(err))
+	if sameTarget && reflect.DeepEqual(prev.Blackout, current.Blackout) {
+		current.Calendar = prev.Calendar
+	} else {
+		calendar, err := blackout.BuildCalendar(current.Blackout)
+		if err != nil {
+			return nil, fmt.Errorf("Sperrzeiten für %s: %w", current.Name, err)
+		}
+		current.Calendar = calendar
 	}
 
-	if err := tmpl.PrepareTemplates(Config); err != nil {
-		logger.Error("Fehler beim Parsen der Templates:", zap.Error(err))
+	if sameTarget && reflect.DeepEqual(prev.RateLimit, current.RateLimit) {
+		current.Limiter = prev.Limiter
+	} else {
+		current.Limiter = ratelimit.Build(current.Name, current.RateLimit)
 	}
 
-	auth, err := auth.BuildAuthProvider(Config.Current.Auth)
-	if err != nil {
-		log.Fatalf("Fehler beim Erzeugen des AuthProviders für %s: %v", Config.Current.Name, err)
+	if sameTarget && reflect.DeepEqual(prev.CircuitBreaker, current.CircuitBreaker) {
+		current.Breaker = prev.Breaker
+	} else {
+		current.Breaker = breaker.Build(current.Name, current.CircuitBreaker)
+	}
+
+	if previous != nil && prev.Seed == current.Seed {
+		current.Random = prev.Random
+	} else {
+		current.Random = clock.NewRandom(current.Seed)
+	}
+	SetClock(current, clock.Or(prev.Clock))
+
+	return cfg, nil
+}
+
+// SetClock setzt die Uhr für die Verarbeitung und alle daraus erzeugten
+// Komponenten eines Targets.
+func SetClock(current *data.CurrentConfig, clk clock.Clock) {
+	current.Clock = clk
+	if o, ok := current.AuthProvider.(*auth.OAuth2Auth); ok {
+		o.Clock = clk
+	}
+	if current.Calendar != nil {
+		current.Calendar.Clock = clk
+	}
+	if current.Limiter != nil {
+		current.Limiter.Clock = clk
+	}
+	if current.Breaker != nil {
+		current.Breaker.Clock = clk
 	}
-	Config.Current.AuthProvider = auth
 }
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// Editoren und ConfigMaps schreiben in mehreren Schritten; erst nach dieser
+// Ruhezeit wird neu geladen.
+const reloadDebounce = 500 * time.Millisecond
+
+const (
+	TriggerFile   = "file"
+	TriggerSignal = "sighup"
+	TriggerAPI    = "api"
+)
+
+// ReloadStatus beschreibt die aktive Konfiguration und den letzten Reload.
+type ReloadStatus struct {
+	File        string    `json:"file,omitempty"`
+	Hash        string    `json:"hash"`
+	LoadedAt    time.Time `json:"loaded_at"`
+	Reloads     int       `json:"reloads"`
+	Trigger     string    `json:"trigger,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+}
+
+var (
+	active    atomic.Pointer[data.WavelyConfig]
+	reloadMu  sync.Mutex
+	status    ReloadStatus
+	listeners []func(*data.WavelyConfig)
+)
+
+// Active liefert die aktuell gültige Konfiguration. Wer mehrere Werte braucht,
+// sollte sie einmal abrufen, um einen konsistenten Stand zu erhalten.
+func Active() *data.WavelyConfig {
+	if cfg := active.Load(); cfg != nil {
+		return cfg
+	}
+	return Config
+}
+
+// OnReload registriert eine Funktion, die nach jedem erfolgreichen Reload mit
+// der neuen Konfiguration aufgerufen wird.
+func OnReload(fn func(*data.WavelyConfig)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+func Status() ReloadStatus {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return status
+}
+
+func activate(cfg *data.WavelyConfig, file string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	active.Store(cfg)
+	status.File = file
+	status.Hash = fileHash(file)
+	status.LoadedAt = time.Now()
+}
+
+// Reload liest die Konfigurationsdatei neu ein. Nur eine vollständig gültige
+// Konfiguration wird übernommen, bei Fehlern bleibt die bisherige aktiv.
+// Unveränderte Dateien werden übersprungen.
+func Reload(trigger string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	v := newViper()
+	if err := v.ReadInConfig(); err != nil {
+		return reloadFailed(trigger, err)
+	}
+	hash := fileHash(v.ConfigFileUsed())
+	if hash == status.Hash && status.LastError == "" {
+		return nil
+	}
+
+	previous := Active()
+	cfg, err := build(v, previous)
+	if err != nil {
+		return reloadFailed(trigger, err)
+	}
+
+	if previous != nil {
+		if cfg.Port != previous.Port {
+			logger.Log.Warn("Änderung des Ports wird erst nach einem Neustart wirksam:", zap.String("port", cfg.Port))
+		}
+		if cfg.Current.Queue.SpillFile != previous.Current.Queue.SpillFile {
+			logger.Log.Warn("Änderung der Auslagerungsdatei wird erst nach einem Neustart wirksam:", zap.String("file", cfg.Current.Queue.SpillFile))
+		}
+	}
+
+	active.Store(cfg)
+	for _, fn := range listeners {
+		fn(cfg)
+	}
+
+	status.File = v.ConfigFileUsed()
+	status.Hash = hash
+	status.LoadedAt = time.Now()
+	status.Reloads++
+	status.Trigger = trigger
+	status.LastError = ""
+	status.LastErrorAt = time.Time{}
+	logger.Log.Info("Konfiguration neu geladen:", zap.String("trigger", trigger), zap.String("hash", hash))
+	return nil
+}
+
+func reloadFailed(trigger string, err error) error {
+	status.Trigger = trigger
+	status.LastError = err.Error()
+	status.LastErrorAt = time.Now()
+	logger.Log.Error("Fehler beim Neuladen der Konfiguration, bisherige bleibt aktiv:", zap.String("trigger", trigger), zap.Error(err))
+	return err
+}
+
+// Watch lädt die Konfiguration neu, sobald sich die Datei ändert oder SIGHUP
+// eintrifft. Überwacht wird das Verzeichnis, da Editoren und ConfigMaps die
+// Datei ersetzen statt sie zu beschreiben.
+func Watch() error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if file := Status().File; file != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			return err
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	go func() {
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-events:
+				debounce = time.After(reloadDebounce)
+			case err := <-errs:
+				logger.Log.Warn("Fehler beim Überwachen der Konfigurationsdatei:", zap.Error(err))
+			case <-debounce:
+				debounce = nil
+				Reload(TriggerFile)
+			case <-hup:
+				Reload(TriggerSignal)
+			}
+		}
+	}()
+	return nil
+}
+
+func fileHash(file string) string {
+	if file == "" {
+		return ""
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
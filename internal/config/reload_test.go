@@ -0,0 +1,95 @@
+package config_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/handlers"
+	"djp.chapter42.de/a/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func initTestLogger() *zap.Logger {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	logger, _ := cfg.Build()
+	return logger
+}
+
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	logger.Log = initTestLogger()
+	return router
+}
+
+func TestConfigReload(t *testing.T) {
+	router := setupRouter()
+	router.GET("/admin/config", handlers.ConfigStatusHandler())
+
+	file := filepath.Join(t.TempDir(), "wavely.cfg.yaml")
+	write := func(auth, workers string) {
+		content := `
+current:
+  name: "reload-target"
+  base_url: "http://localhost"
+  endpoints:
+    check: "/objects/{{.UID}}"
+    revision: "/revision/{{.UID}}"
+    write: "/objects/{{.UID}}"
+  auth:
+    type: "` + auth + `"
+    username: "admin"
+  max_workers: ` + workers + "\n"
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	}
+	write("basic", "2")
+
+	config.ConfigFile = file
+	defer func() { config.ConfigFile = "" }()
+	assert.NoError(t, config.InitConfig())
+	initial := config.Active()
+	initialStatus := config.Status()
+	assert.Equal(t, "reload-target", initial.Current.Name)
+	assert.NotEmpty(t, initialStatus.Hash)
+
+	var reloaded []*data.WavelyConfig
+	config.OnReload(func(cfg *data.WavelyConfig) { reloaded = append(reloaded, cfg) })
+
+	// Unveränderte Datei: kein Reload
+	assert.NoError(t, config.Reload(config.TriggerAPI))
+	assert.Empty(t, reloaded)
+
+	// Gültige Änderung wird übernommen, unveränderter AuthProvider bleibt erhalten
+	write("basic", "4")
+	assert.NoError(t, config.Reload(config.TriggerFile))
+	assert.Len(t, reloaded, 1)
+	assert.Equal(t, 4, config.Active().Current.MaxWorkers)
+	assert.Same(t, initial.Current.AuthProvider, config.Active().Current.AuthProvider)
+	status := config.Status()
+	assert.NotEqual(t, initialStatus.Hash, status.Hash)
+	assert.Equal(t, 1, status.Reloads)
+	assert.Equal(t, config.TriggerFile, status.Trigger)
+
+	// Ungültige Änderung: bisherige Konfiguration bleibt aktiv
+	write("kerberos", "8")
+	assert.Error(t, config.Reload(config.TriggerSignal))
+	assert.Len(t, reloaded, 1)
+	assert.Equal(t, 4, config.Active().Current.MaxWorkers)
+	status = config.Status()
+	assert.Contains(t, status.LastError, "kerberos")
+	assert.Equal(t, 1, status.Reloads)
+
+	req, _ := http.NewRequest("GET", "/admin/config", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), status.Hash)
+}
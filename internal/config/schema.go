@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+)
+
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Schema liefert ein JSON-Schema für wavely.cfg.yaml, erzeugt aus denselben
+// Feldern und Tabellen wie die Prüfung beim Laden.
+func Schema() ([]byte, error) {
+	schema := schemaFor("", reflect.TypeOf(data.WavelyConfig{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Wavely-Konfiguration"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func schemaFor(path string, t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+	if path == "port" {
+		return map[string]any{"type": []string{"string", "integer"}}
+	}
+
+	schema := map[string]any{}
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		for _, f := range settingFields(t) {
+			properties[f.key] = schemaFor(joinPath(path, f.key), f.typ)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if fields, ok := required[path]; ok {
+			schema["required"] = fields
+		}
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = schemaFor(path+".*", t.Elem())
+		if keys, ok := mapKeys[path]; ok {
+			schema["propertyNames"] = map[string]any{"enum": keys}
+		}
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = schemaFor(path+"[]", t.Elem())
+	case reflect.String:
+		schema["type"] = "string"
+		if values, ok := enums[path]; ok {
+			schema["enum"] = values
+		}
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Float64:
+		schema["type"] = "number"
+		schema["minimum"] = 0
+	}
+	return schema
+}
@@ -0,0 +1,18 @@
+package config_test
+
+import (
+	"os"
+	"testing"
+
+	"djp.chapter42.de/a/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSchemaUpToDate(t *testing.T) {
+	schema, err := config.Schema()
+	assert.NoError(t, err)
+	committed, err := os.ReadFile("../../config/wavely.schema.json")
+	assert.NoError(t, err)
+	// Neu erzeugen mit: wavely config schema > config/wavely.schema.json
+	assert.Equal(t, string(schema)+"\n", string(committed))
+}
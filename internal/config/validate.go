@@ -0,0 +1,519 @@
+package config
+
+import (
+	"fmt"
+	"html/template"
+	"maps"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"unicode/utf8"
+
+	"djp.chapter42.de/a/internal/auth"
+	"djp.chapter42.de/a/internal/blackout"
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/poller"
+	"djp.chapter42.de/a/internal/tmpl"
+)
+
+// Erlaubte Werte je Feld. Listenelemente werden mit [] adressiert; die Tabellen
+// dienen der Prüfung und dem JSON-Schema gleichermaßen.
+var enums = map[string][]string{
+	"current.content_type":          convert.Names(),
+	"current.auth.type":             {"basic", "bearer", "oauth2", "none"},
+	"current.duplicate_policy":      {data.DuplicateReject, data.DuplicateReplace, data.DuplicateQueue},
+	"current.batch.mode":            {data.BatchAtomic, data.BatchBestEffort},
+	"current.pollers[].detect":      {data.DetectHash, data.DetectDiff},
+	"current.pollers[].sink.type":   {data.SinkWebhook, data.SinkFile, data.SinkSSE},
+	"current.pipeline[].method":     methods,
+	"current.lock.acquire.method":   methods,
+	"current.lock.renew.method":     methods,
+	"current.lock.release.method":   methods,
+	"current.pipeline[].on_failure": {data.OnFailureRetry, data.OnFailureContinue},
+	"current.pipeline[].rate_limit": rateLimitEndpoints,
+	"current.verify.mode":           {data.VerifyField, data.VerifyChecksum, data.VerifyRevision},
+	"current.verify.algorithm":      {data.ChecksumSHA256, data.ChecksumMD5},
+}
+
+// Endpunkte mit eigenem Limit unter rate_limit.endpoints.
+var rateLimitEndpoints = []string{"check", "revision", "write", "fetch", "verify", "lock"}
+
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// Erlaubte Schlüssel von Maps.
+var mapKeys = map[string][]string{
+	"current.queue.weights":        {data.PriorityHigh, data.PriorityNormal, data.PriorityLow},
+	"current.rate_limit.endpoints": rateLimitEndpoints,
+}
+
+// Pflichtfelder je Objekt.
+var required = map[string][]string{
+	"current":            {"name", "base_url", "auth"},
+	"current.endpoints":  {"check", "revision", "write"},
+	"current.auth":       {"type"},
+	"current.pollers[]":  {"name", "endpoint"},
+	"current.pipeline[]": {"name", "endpoint"},
+}
+
+// Namen von Pipeline-Schritten sind in Templates über .Steps.<name> erreichbar.
+var stepName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Quellen für Ausgaben von Pipeline-Schritten.
+var extractSource = regexp.MustCompile(`^(status|body|body\..+|header\..+)$`)
+
+// Je Auth-Typ benötigte und zusätzlich erlaubte Felder.
+var authFields = map[string]struct{ required, optional []string }{
+	"basic":  {required: []string{"username"}, optional: []string{"password"}},
+	"bearer": {required: []string{"token"}},
+	"oauth2": {required: []string{"client_id", "token_url", "refresh_token"}, optional: []string{"client_secret"}},
+	"none":   {},
+}
+
+// FieldError beschreibt einen Fehler an einem Feld der Konfiguration, z.B.
+// current.pollers[0].sink.url.
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError sammelt alle Fehler einer Konfiguration.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "ungültige Konfiguration: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(path, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate prüft die gelesenen Werte auf unbekannte Schlüssel und die daraus
+// erzeugte Konfiguration auf fehlende oder widersprüchliche Angaben.
+func validate(settings map[string]any, cfg *data.WavelyConfig) error {
+	errs := &ValidationError{}
+	checkKeys(errs, "", settings, reflect.TypeOf(*cfg))
+
+	if cfg.Port != "" {
+		if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+			errs.add("port", "muss eine Portnummer zwischen 1 und 65535 sein, ist %q", cfg.Port)
+		}
+	}
+	nonNegative(errs, "shutdown.grace_period", cfg.Shutdown.GracePeriod)
+
+	current := &cfg.Current
+	requireValue(errs, "current.name", current.Name)
+	if requireValue(errs, "current.base_url", current.BaseURL) {
+		checkURL(errs, "current.base_url", current.BaseURL)
+	}
+	// Mit Pipeline werden die Endpunkte des Standardablaufs nicht benötigt
+	endpoints := map[string]string{
+		"check":    current.Endpoints.Check,
+		"revision": current.Endpoints.Revision,
+		"write":    current.Endpoints.Write,
+	}
+	for _, name := range slices.Sorted(maps.Keys(endpoints)) {
+		if len(current.Pipeline) == 0 {
+			checkTemplate(errs, "current.endpoints."+name, endpoints[name])
+		} else {
+			parseTemplate(errs, "current.endpoints."+name, endpoints[name])
+		}
+	}
+	checkEnum(errs, "current.content_type", "current.content_type", current.ContentType)
+	checkAuth(errs, current.Auth)
+
+	nonNegative(errs, "current.repetitions", current.Repititions)
+	nonNegative(errs, "current.min_workers", current.MinWorkers)
+	if current.MaxWorkers < 1 {
+		errs.add("current.max_workers", "muss mindestens 1 sein, ist %d", current.MaxWorkers)
+	} else if current.MinWorkers > current.MaxWorkers {
+		errs.add("current.min_workers", "darf nicht größer als max_workers (%d) sein, ist %d", current.MaxWorkers, current.MinWorkers)
+	}
+
+	checkEnum(errs, "current.duplicate_policy", "current.duplicate_policy", strings.ToLower(current.DuplicatePolicy))
+	nonNegative(errs, "current.idempotency.window", current.Idempotency.Window)
+	checkEnum(errs, "current.batch.mode", "current.batch.mode", strings.ToLower(current.Batch.Mode))
+	nonNegative(errs, "current.batch.max_items", current.Batch.MaxItems)
+
+	nonNegative(errs, "current.queue.capacity", current.Queue.Capacity)
+	nonNegative(errs, "current.queue.spill_limit", current.Queue.SpillLimit)
+	for name, weight := range current.Queue.Weights {
+		nonNegative(errs, "current.queue.weights."+name, weight)
+	}
+	nonNegative(errs, "current.restore.rate", current.Restore.Rate)
+
+	names := make(map[string]int)
+	for i, pc := range current.Pollers {
+		checkPoller(errs, fmt.Sprintf("current.pollers[%d]", i), pc)
+		if first, ok := names[pc.Name]; ok && pc.Name != "" {
+			errs.add(fmt.Sprintf("current.pollers[%d].name", i), "%q ist bereits bei current.pollers[%d] vergeben", pc.Name, first)
+		} else {
+			names[pc.Name] = i
+		}
+	}
+
+	steps := make(map[string]int)
+	for i, step := range current.Pipeline {
+		path := fmt.Sprintf("current.pipeline[%d]", i)
+		checkStep(errs, path, step)
+		if first, ok := steps[step.Name]; ok && step.Name != "" {
+			errs.add(path+".name", "%q ist bereits bei current.pipeline[%d] vergeben", step.Name, first)
+		} else {
+			steps[step.Name] = i
+		}
+	}
+
+	checkConvert(errs, current.Convert)
+	parseStepTemplate(errs, "current.transform", current.Transform)
+	checkVerify(errs, current.Verify)
+	checkLock(errs, current.Lock)
+
+	if _, err := blackout.BuildCalendar(current.Blackout); err != nil {
+		errs.add("current.blackout", "%v", err)
+	}
+
+	nonNegative(errs, "current.rate_limit.requests_per_second", current.RateLimit.RequestsPerSecond)
+	nonNegative(errs, "current.rate_limit.burst", current.RateLimit.Burst)
+	for name, limit := range current.RateLimit.Endpoints {
+		nonNegative(errs, "current.rate_limit.endpoints."+name+".requests_per_second", limit.RequestsPerSecond)
+		nonNegative(errs, "current.rate_limit.endpoints."+name+".burst", limit.Burst)
+	}
+
+	cb := current.CircuitBreaker
+	nonNegative(errs, "current.circuit_breaker.consecutive_failures", cb.ConsecutiveFailures)
+	if cb.ErrorRate < 0 || cb.ErrorRate > 1 {
+		errs.add("current.circuit_breaker.error_rate", "muss zwischen 0 und 1 liegen, ist %v", cb.ErrorRate)
+	}
+	nonNegative(errs, "current.circuit_breaker.window", cb.Window)
+	nonNegative(errs, "current.circuit_breaker.min_requests", cb.MinRequests)
+	nonNegative(errs, "current.circuit_breaker.open_timeout", cb.OpenTimeout)
+
+	if len(errs.Errors) > 0 {
+		sort.SliceStable(errs.Errors, func(i, j int) bool { return errs.Errors[i].Path < errs.Errors[j].Path })
+		return errs
+	}
+	return nil
+}
+
+func checkPoller(errs *ValidationError, path string, pc data.PollerConfig) {
+	requireValue(errs, path+".name", pc.Name)
+	checkTemplate(errs, path+".endpoint", pc.Endpoint)
+
+	switch {
+	case pc.Interval > 0 && pc.Cron != "":
+		errs.add(path, "interval und cron schließen sich aus")
+	case pc.Interval < 0:
+		errs.add(path+".interval", "darf nicht negativ sein, ist %v", pc.Interval)
+	case pc.Interval == 0 && pc.Cron == "":
+		errs.add(path, "benötigt interval oder cron")
+	case pc.Cron != "":
+		if _, err := poller.ParseCron(pc.Cron); err != nil {
+			errs.add(path+".cron", "%v", err)
+		}
+	}
+	if pc.Spread < 0 || pc.Spread > 1 {
+		errs.add(path+".spread", "muss zwischen 0 und 1 liegen, ist %v", pc.Spread)
+	}
+	checkEnum(errs, path+".detect", "current.pollers[].detect", pc.Detect)
+
+	checkEnum(errs, path+".sink.type", "current.pollers[].sink.type", pc.Sink.Type)
+	switch pc.Sink.Type {
+	case data.SinkWebhook:
+		if requireValue(errs, path+".sink.url", pc.Sink.URL) {
+			checkURL(errs, path+".sink.url", pc.Sink.URL)
+		}
+	case data.SinkFile:
+		requireValue(errs, path+".sink.path", pc.Sink.Path)
+	}
+}
+
+func checkStep(errs *ValidationError, path string, step data.StepConfig) {
+	if requireValue(errs, path+".name", step.Name) && !stepName.MatchString(step.Name) {
+		errs.add(path+".name", "darf nur a-z, 0-9 und _ enthalten und muss mit einem Buchstaben beginnen, ist %q", step.Name)
+	}
+	checkEnum(errs, path+".method", "current.pipeline[].method", strings.ToUpper(step.Method))
+	checkEnum(errs, path+".on_failure", "current.pipeline[].on_failure", step.OnFailure)
+	checkEnum(errs, path+".rate_limit", "current.pipeline[].rate_limit", step.RateLimit)
+
+	if requireValue(errs, path+".endpoint", step.Endpoint) {
+		parseStepTemplate(errs, path+".endpoint", step.Endpoint)
+	}
+	parseStepTemplate(errs, path+".body", step.Body)
+	for _, name := range slices.Sorted(maps.Keys(step.Headers)) {
+		parseStepTemplate(errs, path+".headers."+name, step.Headers[name])
+	}
+	parseStepTemplate(errs, path+".success.condition", step.Success.Condition)
+
+	for _, name := range slices.Sorted(maps.Keys(step.Extract)) {
+		if !extractSource.MatchString(step.Extract[name]) {
+			errs.add(path+".extract."+name, "unbekannte Quelle %q, erlaubt sind status, body, body.<pfad> und header.<name>", step.Extract[name])
+		}
+	}
+	checkStatus(errs, path+".success.status", step.Success.Status)
+}
+
+func checkConvert(errs *ValidationError, opts convert.Options) {
+	xmlOpts := opts.XML
+	for _, field := range []struct{ key, name string }{{"root", xmlOpts.Root}, {"item", xmlOpts.Item}} {
+		if field.name != "" && convert.CheckName(field.name) != nil {
+			errs.add("current.convert.xml."+field.key, "muss ein gültiger XML-Name sein, ist %q", field.name)
+		}
+	}
+	for _, prefix := range slices.Sorted(maps.Keys(xmlOpts.Namespaces)) {
+		if convert.CheckName(prefix) != nil || strings.Contains(prefix, ":") {
+			errs.add("current.convert.xml.namespaces."+prefix, "muss ein gültiges Namespace-Präfix sein")
+		}
+	}
+	if xmlOpts.AttributePrefix != "" && strings.HasPrefix(xmlOpts.TextKey, xmlOpts.AttributePrefix) {
+		errs.add("current.convert.xml.text_key", "darf nicht mit attribute_prefix %q beginnen", xmlOpts.AttributePrefix)
+	}
+	if d := opts.CSV.Delimiter; d != "" && (utf8.RuneCountInString(d) != 1 || strings.ContainsAny(d, "\"\r\n")) {
+		errs.add("current.convert.csv.delimiter", "muss ein einzelnes Zeichen außer Anführungszeichen und Zeilenumbruch sein, ist %q", d)
+	}
+}
+
+func checkVerify(errs *ValidationError, verify data.VerifyConfig) {
+	if verify.Mode == "" || !checkEnum(errs, "current.verify.mode", "current.verify.mode", verify.Mode) {
+		return
+	}
+	if requireValue(errs, "current.verify.endpoint", verify.Endpoint) {
+		parseStepTemplate(errs, "current.verify.endpoint", verify.Endpoint)
+	}
+	parseStepTemplate(errs, "current.verify.expected", verify.Expected)
+	checkEnum(errs, "current.verify.algorithm", "current.verify.algorithm", verify.Algorithm)
+	nonNegative(errs, "current.verify.attempts", verify.Attempts)
+	nonNegative(errs, "current.verify.interval", verify.Interval)
+
+	switch {
+	case verify.Field != "" && !extractSource.MatchString(verify.Field):
+		errs.add("current.verify.field", "unbekannte Quelle %q, erlaubt sind status, body, body.<pfad> und header.<name>", verify.Field)
+	case verify.Mode == data.VerifyChecksum:
+	case verify.Field == "":
+		errs.add("current.verify.field", "fehlt, wird bei Modus %s benötigt", verify.Mode)
+	case verify.Mode == data.VerifyField && verify.Expected == "" && !strings.HasPrefix(verify.Field, "body."):
+		errs.add("current.verify.field", "muss ohne expected ein Pfad body.<pfad> sein, ist %q", verify.Field)
+	}
+}
+
+func checkLock(errs *ValidationError, lock data.LockConfig) {
+	if reflect.DeepEqual(lock, data.LockConfig{}) {
+		return
+	}
+	checkRequest(errs, "current.lock.acquire", lock.Acquire, true)
+	checkRequest(errs, "current.lock.renew", lock.Renew, false)
+	checkRequest(errs, "current.lock.release", lock.Release, true)
+
+	if requireValue(errs, "current.lock.token", lock.Token) && !extractSource.MatchString(lock.Token) {
+		errs.add("current.lock.token", "unbekannte Quelle %q, erlaubt sind status, body, body.<pfad> und header.<name>", lock.Token)
+	}
+	nonNegative(errs, "current.lock.lease", lock.Lease)
+	if lock.Renew.Endpoint != "" && lock.Lease <= 0 {
+		errs.add("current.lock.lease", "fehlt, wird zum Verlängern über renew benötigt")
+	}
+}
+
+func checkRequest(errs *ValidationError, path string, request data.RequestConfig, mandatory bool) {
+	if request.Endpoint == "" {
+		if mandatory {
+			errs.add(path+".endpoint", "fehlt")
+		}
+		return
+	}
+	parseStepTemplate(errs, path+".endpoint", request.Endpoint)
+	checkEnum(errs, path+".method", path+".method", strings.ToUpper(request.Method))
+	parseStepTemplate(errs, path+".body", request.Body)
+	for _, name := range slices.Sorted(maps.Keys(request.Headers)) {
+		parseStepTemplate(errs, path+".headers."+name, request.Headers[name])
+	}
+	checkStatus(errs, path+".status", request.Status)
+}
+
+func checkStatus(errs *ValidationError, path string, codes []int) {
+	for i, status := range codes {
+		if status < 100 || status > 599 {
+			errs.add(fmt.Sprintf("%s[%d]", path, i), "muss ein HTTP-Statuscode sein, ist %d", status)
+		}
+	}
+}
+
+func checkAuth(errs *ValidationError, cfg auth.AuthConfig) {
+	authType := strings.ToLower(cfg.Type)
+	if !requireValue(errs, "current.auth.type", authType) || !checkEnum(errs, "current.auth.type", "current.auth.type", authType) {
+		return
+	}
+
+	values := map[string]string{
+		"username":      cfg.Username,
+		"password":      cfg.Password,
+		"token":         cfg.Token,
+		"client_id":     cfg.ClientID,
+		"client_secret": cfg.ClientSecret,
+		"token_url":     cfg.TokenURL,
+		"refresh_token": cfg.RefreshToken,
+	}
+	fields := authFields[authType]
+	for _, name := range fields.required {
+		requireValue(errs, "current.auth."+name, values[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if values[name] != "" && !slices.Contains(fields.required, name) && !slices.Contains(fields.optional, name) {
+			errs.add("current.auth."+name, "wird bei Auth-Typ %s nicht verwendet", authType)
+		}
+	}
+	if authType == "oauth2" && cfg.TokenURL != "" {
+		checkURL(errs, "current.auth.token_url", cfg.TokenURL)
+	}
+}
+
+// checkKeys meldet Schlüssel, die keinem Feld der Konfiguration entsprechen,
+// etwa Tippfehler wie max_worker.
+func checkKeys(errs *ValidationError, path string, value any, t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		known := make(map[string]reflect.Type)
+		for _, f := range settingFields(t) {
+			known[f.key] = f.typ
+		}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			if typ, ok := known[key]; ok {
+				checkKeys(errs, joinPath(path, key), values[key], typ)
+			} else {
+				errs.add(joinPath(path, key), "unbekannter Schlüssel")
+			}
+		}
+	case reflect.Map:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		allowed := mapKeys[genericPath(path)]
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			if allowed != nil && !slices.Contains(allowed, key) {
+				errs.add(joinPath(path, key), "unbekannter Schlüssel, erlaubt sind %s", strings.Join(allowed, ", "))
+				continue
+			}
+			checkKeys(errs, joinPath(path, key), values[key], t.Elem())
+		}
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			checkKeys(errs, fmt.Sprintf("%s[%d]", path, i), item, t.Elem())
+		}
+	}
+}
+
+type settingField struct {
+	key string
+	typ reflect.Type
+}
+
+// settingFields liefert die über mapstructure konfigurierbaren Felder eines
+// Structs. Felder ohne Tag (Templates, AuthProvider, ...) entstehen zur Laufzeit.
+func settingFields(t reflect.Type) []settingField {
+	var fields []settingField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if strings.Contains(opts, "squash") {
+			fields = append(fields, settingFields(f.Type)...)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, settingField{key: name, typ: f.Type})
+	}
+	return fields
+}
+
+func requireValue(errs *ValidationError, path, value string) bool {
+	if value == "" {
+		errs.add(path, "fehlt")
+		return false
+	}
+	return true
+}
+
+func checkEnum(errs *ValidationError, path, key, value string) bool {
+	if value == "" || slices.Contains(enums[key], value) {
+		return true
+	}
+	errs.add(path, "unbekannter Wert %q, erlaubt sind %s", value, strings.Join(enums[key], ", "))
+	return false
+}
+
+func checkTemplate(errs *ValidationError, path, value string) {
+	if requireValue(errs, path, value) {
+		parseTemplate(errs, path, value)
+	}
+}
+
+func parseTemplate(errs *ValidationError, path, value string) {
+	if _, err := template.New(path).Parse(value); err != nil {
+		errs.add(path, "ungültiges Template: %v", err)
+	}
+}
+
+// parseStepTemplate prüft Templates der Pipeline samt ihrer Funktionen.
+func parseStepTemplate(errs *ValidationError, path, value string) {
+	if _, err := texttemplate.New(path).Funcs(tmpl.StepFuncs).Parse(value); err != nil {
+		errs.add(path, "ungültiges Template: %v", err)
+	}
+}
+
+func checkURL(errs *ValidationError, path, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(path, "muss eine absolute http(s)-URL sein, ist %q", value)
+	}
+}
+
+func nonNegative[T int | float64 | ~int64](errs *ValidationError, path string, value T) {
+	if value < 0 {
+		errs.add(path, "darf nicht negativ sein, ist %v", value)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// genericPath ersetzt Listenindizes durch [], z.B. current.pollers[2].detect
+// zu current.pollers[].detect.
+func genericPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		b.WriteByte(path[i])
+		if path[i] == '[' {
+			for i+1 < len(path) && path[i+1] != ']' {
+				i++
+			}
+		}
+	}
+	return b.String()
+}
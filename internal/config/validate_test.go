@@ -0,0 +1,95 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"djp.chapter42.de/a/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "wavely.cfg.yaml")
+	content := `
+port: 99999
+current:
+  name: "invalid-target"
+  endpoints:
+    check: "/objects/{{.UID"
+    revision: "/revision/{{.UID}}"
+    write: "/objects/{{.UID}}"
+  auth:
+    type: "basic"
+    username: "admin"
+    token: "secret"
+  min_workers: 5
+  max_worker: 2
+  max_workers: 2
+  queue:
+    weights:
+      urgent: 3
+  pollers:
+    - name: "status"
+      endpoint: "/status"
+      interval: "30s"
+      cron: "* * * * *"
+      sink:
+        type: "webhook"
+  pipeline:
+    - name: "Fetch"
+      endpoint: "/objects/{{.UID}}"
+      extract:
+        rev: "cookie.rev"
+  verify:
+    mode: "revision"
+    endpoint: "/objects/{{.UID}}"
+  lock:
+    acquire:
+      endpoint: "/locks/{{.UID}}"
+    renew:
+      endpoint: "/locks/{{.UID}}/{{.Lock.Token}}"
+    token: "body.token"
+  content_type: "toml"
+  convert:
+    xml:
+      root: "1data"
+    csv:
+      delimiter: ";;"
+  transform: "{{.Value"
+`
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	err := config.Check(file)
+	var invalid *config.ValidationError
+	if !assert.ErrorAs(t, err, &invalid) {
+		return
+	}
+	var paths []string
+	for _, fieldErr := range invalid.Errors {
+		paths = append(paths, fieldErr.Path)
+	}
+	assert.Equal(t, []string{
+		"current.auth.token",
+		"current.base_url",
+		"current.content_type",
+		"current.convert.csv.delimiter",
+		"current.convert.xml.root",
+		"current.endpoints.check",
+		"current.lock.lease",
+		"current.lock.release.endpoint",
+		"current.max_worker",
+		"current.min_workers",
+		"current.pipeline[0].extract.rev",
+		"current.pipeline[0].name",
+		"current.pollers[0]",
+		"current.pollers[0].sink.url",
+		"current.queue.weights.urgent",
+		"current.transform",
+		"current.verify.field",
+		"port",
+	}, paths)
+
+	// Die mitgelieferte Beispielkonfiguration ist gültig
+	assert.NoError(t, config.Check("../../config/wavely.cfg.yaml"))
+}
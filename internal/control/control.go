@@ -0,0 +1,127 @@
+package control
+
+import (
+	"sync"
+)
+
+const (
+	StateRunning = "running"
+	// Jobs werden angenommen, aber nicht versucht
+	StatePaused = "paused"
+	// Laufende Versuche werden beendet, neue Jobs und Versuche abgelehnt
+	StateDraining = "draining"
+)
+
+// State ist der globale Betriebszustand aller Targets.
+var State = New()
+
+// Controls hält den Zustand je Target und den globalen Wartungsmodus. Wartende
+// werden über einen Kanal geweckt, der bei jeder Änderung geschlossen wird.
+type Controls struct {
+	mu          sync.Mutex
+	targets     map[string]string
+	maintenance bool
+	changed     chan struct{}
+}
+
+// Snapshot ist der persistierte Zustand.
+type Snapshot struct {
+	Maintenance bool              `json:"maintenance"`
+	Targets     map[string]string `json:"targets,omitempty"`
+}
+
+func New() *Controls {
+	return &Controls{targets: make(map[string]string), changed: make(chan struct{})}
+}
+
+func (c *Controls) Pause(target string)  { c.set(target, StatePaused) }
+func (c *Controls) Resume(target string) { c.set(target, StateRunning) }
+func (c *Controls) Drain(target string)  { c.set(target, StateDraining) }
+
+func (c *Controls) SetMaintenance(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maintenance = enabled
+	c.notify()
+}
+
+// Target liefert den Zustand eines Targets.
+func (c *Controls) Target(target string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.target(target)
+}
+
+func (c *Controls) Maintenance() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maintenance
+}
+
+// Check meldet, ob Versuche gegen das Target erlaubt sind. Falls nicht, wird der
+// Kanal geschlossen, sobald sich der Zustand ändert.
+func (c *Controls) Check(target string) (bool, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.maintenance && c.target(target) == StateRunning, c.changed
+}
+
+// Allowed meldet, ob Versuche gegen das Target erlaubt sind.
+func (c *Controls) Allowed(target string) bool {
+	allowed, _ := c.Check(target)
+	return allowed
+}
+
+// Accepting meldet, ob neue Jobs für das Target angenommen werden.
+func (c *Controls) Accepting(target string) bool {
+	return c.Target(target) != StateDraining
+}
+
+func (c *Controls) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	targets := make(map[string]string, len(c.targets))
+	for name, state := range c.targets {
+		targets[name] = state
+	}
+	return Snapshot{Maintenance: c.maintenance, Targets: targets}
+}
+
+func (c *Controls) Load(s Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maintenance = s.Maintenance
+	c.targets = make(map[string]string, len(s.Targets))
+	for name, state := range s.Targets {
+		if state != StateRunning {
+			c.targets[name] = state
+		}
+	}
+	c.notify()
+}
+
+func (c *Controls) set(target, state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == StateRunning {
+		delete(c.targets, target)
+	} else {
+		c.targets[target] = state
+	}
+	c.notify()
+}
+
+func (c *Controls) target(target string) string {
+	if state, ok := c.targets[target]; ok {
+		return state
+	}
+	return StateRunning
+}
+
+func (c *Controls) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
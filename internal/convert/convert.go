@@ -1,31 +1,247 @@
+// Package convert überführt Payloads zwischen den Datenformaten, die Clients
+// einreichen und Targets erwarten (JSON, XML, Formular, CSV, YAML, MessagePack).
 package convert
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+)
 
-	"djp.chapter42.de/a/internal/logger"
+// Namen der eingebauten Formate, wie sie in content_type angegeben werden.
+const (
+	JSON    = "json"
+	XML     = "xml"
+	Form    = "form"
+	CSV     = "csv"
+	YAML    = "yaml"
+	MsgPack = "msgpack"
 )
 
-func MapToXML(m map[string]interface{}) []byte {
-	var b strings.Builder
-	for k, v := range m {
-		switch v := v.(type) {
-		case string:
-			b.WriteString(fmt.Sprintf("<%s>%s</%s>", k, v, k))
-		case float64, int, bool:
-			b.WriteString(fmt.Sprintf("<%s>%v</%s>", k, v, k))
-		case map[string]interface{}:
-			b.WriteString(fmt.Sprintf("<%s>%s</%s>", k, MapToXML(v), k))
-		case []interface{}:
-			for _, item := range v {
-				if m2, ok := item.(map[string]interface{}); ok {
-					b.WriteString(fmt.Sprintf("<%s>%s</%s>", k, MapToXML(m2), k))
-				}
+// Codec liest und schreibt ein Datenformat. Werte haben dieselbe Form wie bei
+// encoding/json mit UseNumber: map[string]any, []any, string, json.Number,
+// bool und nil. Encode erzeugt für denselben Wert immer dieselbe Ausgabe.
+type Codec interface {
+	Decode(payload []byte) (any, error)
+	Encode(value any) ([]byte, error)
+}
+
+// Format ist ein registriertes Datenformat.
+type Format struct {
+	MIME string
+	// New erzeugt den Codec mit den Einstellungen des Targets
+	New func(opts Options) Codec
+}
+
+// Options sind die Einstellungen der Formate eines Targets.
+type Options struct {
+	XML XMLOptions `mapstructure:"xml"`
+	CSV CSVOptions `mapstructure:"csv"`
+}
+
+var registry = struct {
+	sync.RWMutex
+	formats map[string]Format
+}{formats: make(map[string]Format)}
+
+// Register macht ein Format unter name verfügbar bzw. ersetzt es.
+func Register(name string, format Format) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.formats[name] = format
+}
+
+// Lookup liefert das Format zu einem content_type.
+func Lookup(name string) (Format, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	format, ok := registry.formats[name]
+	return format, ok
+}
+
+// Names liefert die Namen aller registrierten Formate, sortiert.
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Sorted(maps.Keys(registry.formats))
+}
+
+// MIME liefert den Content-Type eines Formats, für unbekannte Formate "".
+func MIME(name string) string {
+	format, _ := Lookup(name)
+	return format.MIME
+}
+
+// Decode liest einen Payload im Format from.
+func Decode(payload []byte, from string, opts Options) (any, error) {
+	format, ok := Lookup(from)
+	if !ok {
+		return nil, fmt.Errorf("unbekanntes Format %q", from)
+	}
+	value, err := format.New(opts).Decode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Payload ist kein gültiges %s: %w", from, err)
+	}
+	return value, nil
+}
+
+// Encode schreibt einen Wert im Format to.
+func Encode(value any, to string, opts Options) ([]byte, error) {
+	format, ok := Lookup(to)
+	if !ok {
+		return nil, fmt.Errorf("unbekanntes Format %q", to)
+	}
+	out, err := format.New(opts).Encode(Normalize(value))
+	if err != nil {
+		return nil, fmt.Errorf("Payload nicht als %s darstellbar: %w", to, err)
+	}
+	return out, nil
+}
+
+// Convert überführt einen Payload von from nach to. Bei gleichem Format wird
+// der Payload unverändert übernommen.
+func Convert(payload []byte, from, to string, opts Options) ([]byte, error) {
+	if from == to {
+		return payload, nil
+	}
+	value, err := Decode(payload, from, opts)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(value, to, opts)
+}
+
+// Path folgt in einem dekodierten Wert einem Pfad aus Schlüsseln und
+// Listenindizes, z.B. items.0.id.
+func Path(value any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			var ok bool
+			if value, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
 			}
+			value = node[index]
 		default:
-			logger.Log.Warn("Encountered undefined datatype in map.")
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Normalize bringt Werte aus Decodern und Go-Code in die Form der Codecs:
+// Zahlen werden zu json.Number, Maps erhalten Zeichenketten als Schlüssel.
+func Normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = Normalize(item)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[fmt.Sprint(key)] = Normalize(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = Normalize(item)
+		}
+		return out
+	case []byte:
+		return string(v)
+	case int:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int8:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int16:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int32:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint8:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+// native wandelt json.Number für Encoder ohne eigenes Zahlenformat in int64,
+// uint64 oder float64.
+func native(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = native(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = native(item)
+		}
+		return out
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
 		}
+		if f, err := v.Float64(); err == nil && !math.IsInf(f, 0) {
+			return f
+		}
+		return string(v)
+	}
+	return value
+}
+
+// scalar liefert einen einfachen Wert als Text, z.B. für XML, CSV und Formulare.
+// Objekte und Listen werden als JSON geschrieben.
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
 	}
-	return []byte(b.String())
+	out, err := json.Marshal(value)
+	return string(out), err
+}
+
+func sortedKeys(m map[string]any) []string {
+	return slices.Sorted(maps.Keys(m))
 }
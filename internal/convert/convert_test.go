@@ -0,0 +1,113 @@
+package convert_test
+
+import (
+	"testing"
+
+	"djp.chapter42.de/a/internal/convert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	payload := []byte(`{"id":7,"name":"A & <B>","price":1.5,"active":true,"none":null,` +
+		`"tags":["x","y"],"meta":{"owner":"ops","level":2},"items":[{"sku":"a1","qty":1},{"sku":"b2","qty":3}]}`)
+	value, err := convert.Decode(payload, convert.JSON, convert.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// JSON, YAML und MessagePack erhalten Typen und Struktur
+	for _, format := range []string{convert.JSON, convert.YAML, convert.MsgPack} {
+		encoded, err := convert.Encode(value, format, convert.Options{})
+		if !assert.NoError(t, err, format) {
+			continue
+		}
+		again, err := convert.Encode(value, format, convert.Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, encoded, again, "%s ist nicht deterministisch", format)
+
+		decoded, err := convert.Decode(encoded, format, convert.Options{})
+		assert.NoError(t, err, format)
+		assert.Equal(t, value, decoded, format)
+	}
+
+	// XML und Formulare kennen nur Text: Struktur bleibt, Werte werden Zeichenketten
+	asText := map[string]any{
+		"id": "7", "name": "A & <B>", "price": "1.5", "active": "true", "none": "",
+		"tags":  []any{"x", "y"},
+		"meta":  map[string]any{"owner": "ops", "level": "2"},
+		"items": []any{map[string]any{"sku": "a1", "qty": "1"}, map[string]any{"sku": "b2", "qty": "3"}},
+	}
+	for _, format := range []string{convert.XML, convert.Form} {
+		encoded, err := convert.Encode(value, format, convert.Options{})
+		if !assert.NoError(t, err, format) {
+			continue
+		}
+		for range 5 {
+			again, _ := convert.Encode(value, format, convert.Options{})
+			assert.Equal(t, encoded, again, "%s ist nicht deterministisch", format)
+		}
+		decoded, err := convert.Decode(encoded, format, convert.Options{})
+		assert.NoError(t, err, format)
+		assert.Equal(t, asText, decoded, format)
+	}
+
+	xmlOut, err := convert.Convert(payload, convert.JSON, convert.XML, convert.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<data><active>true</active><id>7</id><items><qty>1</qty><sku>a1</sku></items><items><qty>3</qty><sku>b2</sku></items>`+
+		`<meta><level>2</level><owner>ops</owner></meta><name>A &amp; &lt;B&gt;</name><none></none><price>1.5</price>`+
+		`<tags>x</tags><tags>y</tags></data>`, string(xmlOut))
+
+	formOut, err := convert.Convert([]byte(`{"a":{"b":"1 2"},"list":["x","y"],"rows":[{"k":"v"}]}`), convert.JSON, convert.Form, convert.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a%5Bb%5D=1+2&list=x&list=y&rows%5B0%5D%5Bk%5D=v", string(formOut))
+
+	// Wurzelelement, Attribute und Namespaces
+	opts := convert.Options{XML: convert.XMLOptions{
+		Root:       "order",
+		Namespace:  "urn:example:order",
+		Namespaces: map[string]string{"ex": "urn:example:ext"},
+	}}
+	order := []byte(`{"@id":"o-1","ex:note":"eilig","line":{"@sku":"a\"1","#text":"2"}}`)
+	xmlOut, err = convert.Convert(order, convert.JSON, convert.XML, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<order xmlns="urn:example:order" xmlns:ex="urn:example:ext" id="o-1"><ex:note>eilig</ex:note><line sku="a&#34;1">2</line></order>`, string(xmlOut))
+	back, err := convert.Convert(xmlOut, convert.XML, convert.JSON, opts)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(order), string(back))
+
+	// Listen ohne eigenen Namen unter der Wurzel
+	xmlOut, err = convert.Convert([]byte(`[1,"zwei"]`), convert.JSON, convert.XML, convert.Options{})
+	assert.NoError(t, err)
+	assert.Contains(t, string(xmlOut), "<data><item>1</item><item>zwei</item></data>")
+	back, err = convert.Convert(xmlOut, convert.XML, convert.JSON, convert.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `["1","zwei"]`, string(back))
+
+	// CSV: Kopfzeile aus allen Schlüsseln, verschachtelte Werte als JSON
+	rows := []byte(`[{"sku":"a1","qty":1,"dims":{"w":2}},{"sku":"b;2","note":"x"}]`)
+	csvOpts := convert.Options{CSV: convert.CSVOptions{Delimiter: ";"}}
+	csvOut, err := convert.Convert(rows, convert.JSON, convert.CSV, csvOpts)
+	assert.NoError(t, err)
+	assert.Equal(t, "dims;note;qty;sku\n\"{\"\"w\"\":2}\";;1;a1\n;x;;\"b;2\"\n", string(csvOut))
+	decoded, err := convert.Decode(csvOut, convert.CSV, csvOpts)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{
+		map[string]any{"dims": `{"w":2}`, "note": "", "qty": "1", "sku": "a1"},
+		map[string]any{"dims": "", "note": "x", "qty": "", "sku": "b;2"},
+	}, decoded)
+
+	// Fehler und Formate
+	_, err = convert.Convert([]byte(`{"a":1} {"b":2}`), convert.JSON, convert.YAML, convert.Options{})
+	assert.Error(t, err)
+	_, err = convert.Convert([]byte(`{"a b":1}`), convert.JSON, convert.XML, convert.Options{})
+	assert.Error(t, err)
+	_, err = convert.Convert([]byte(`"text"`), convert.JSON, convert.CSV, convert.Options{})
+	assert.Error(t, err)
+	same, err := convert.Convert([]byte("kein json"), convert.JSON, convert.JSON, convert.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "kein json", string(same))
+	assert.Equal(t, []string{"csv", "form", "json", "msgpack", "xml", "yaml"}, convert.Names())
+	assert.Equal(t, "application/x-www-form-urlencoded", convert.MIME(convert.Form))
+}
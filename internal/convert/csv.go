@@ -0,0 +1,103 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"maps"
+	"slices"
+	"unicode/utf8"
+)
+
+func init() {
+	Register(CSV, Format{MIME: "text/csv", New: func(opts Options) Codec { return csvCodec{opts.CSV} }})
+}
+
+// CSVOptions legen das Trennzeichen für CSV fest.
+type CSVOptions struct {
+	// Ein einzelnes Zeichen (Standard: ,)
+	Delimiter string `mapstructure:"delimiter"`
+}
+
+func (o CSVOptions) comma() rune {
+	if r, _ := utf8.DecodeRuneInString(o.Delimiter); r != utf8.RuneError {
+		return r
+	}
+	return ','
+}
+
+// csvCodec schreibt eine Liste von Objekten (oder ein einzelnes Objekt) als
+// Tabelle. Die Kopfzeile enthält alle Schlüssel sortiert, verschachtelte Werte
+// stehen als JSON in der Zelle.
+type csvCodec struct {
+	opts CSVOptions
+}
+
+func (c csvCodec) Encode(value any) ([]byte, error) {
+	var rows []map[string]any
+	switch v := value.(type) {
+	case map[string]any:
+		rows = append(rows, v)
+	case []any:
+		for _, item := range v {
+			row, ok := item.(map[string]any)
+			if !ok {
+				return nil, errors.New("CSV benötigt eine Liste von Objekten")
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, errors.New("CSV benötigt ein Objekt oder eine Liste von Objekten")
+	}
+
+	columns := make(map[string]bool)
+	for _, row := range rows {
+		for key := range row {
+			columns[key] = true
+		}
+	}
+	header := slices.Sorted(maps.Keys(columns))
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = c.opts.comma()
+	w.Write(header)
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, key := range header {
+			text, err := scalar(row[key])
+			if err != nil {
+				return nil, err
+			}
+			record[i] = text
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Decode liefert eine Liste von Objekten mit den Spalten der Kopfzeile als
+// Schlüsseln; alle Werte sind Zeichenketten.
+func (c csvCodec) Decode(payload []byte) (any, error) {
+	r := csv.NewReader(bytes.NewReader(payload))
+	r.Comma = c.opts.comma()
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Kopfzeile fehlt")
+	}
+
+	header := records[0]
+	rows := make([]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, key := range header {
+			row[key] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,152 @@
+package convert
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(Form, Format{MIME: "application/x-www-form-urlencoded", New: func(Options) Codec { return formCodec{} }})
+}
+
+// formCodec bildet Objekte auf Formulardaten ab. Verschachtelte Objekte werden
+// als a[b]=..., Listen einfacher Werte als wiederholte Schlüssel und Listen von
+// Objekten als a[0][b]=... geschrieben.
+type formCodec struct{}
+
+func (formCodec) Encode(value any) ([]byte, error) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("Formulardaten benötigen ein Objekt")
+	}
+	values := url.Values{}
+	if err := flatten(values, "", m); err != nil {
+		return nil, err
+	}
+	// Encode sortiert nach Schlüsseln
+	return []byte(values.Encode()), nil
+}
+
+func flatten(values url.Values, prefix string, value any) error {
+	key := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "[" + name + "]"
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range sortedKeys(v) {
+			if err := flatten(values, key(name), v[name]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		simple := !slices.ContainsFunc(v, func(item any) bool {
+			switch item.(type) {
+			case map[string]any, []any:
+				return true
+			}
+			return false
+		})
+		for i, item := range v {
+			if simple {
+				text, _ := scalar(item)
+				values.Add(prefix, text)
+			} else if err := flatten(values, prefix+"["+strconv.Itoa(i)+"]", item); err != nil {
+				return err
+			}
+		}
+	default:
+		text, err := scalar(v)
+		if err != nil {
+			return err
+		}
+		values.Add(prefix, text)
+	}
+	return nil
+}
+
+// Decode liefert alle Werte als Zeichenketten. Ein einzelner Wert einer Liste
+// ist nicht von einem einfachen Wert zu unterscheiden.
+func (formCodec) Decode(payload []byte) (any, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(string(payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	root := make(map[string]any)
+	for key, list := range values {
+		path, err := parseKey(key)
+		if err != nil {
+			return nil, err
+		}
+		var value any = list[0]
+		if len(list) > 1 {
+			items := make([]any, len(list))
+			for i, item := range list {
+				items[i] = item
+			}
+			value = items
+		}
+
+		node := root
+		for _, segment := range path[:len(path)-1] {
+			child, ok := node[segment].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[segment] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = value
+	}
+	return indexedLists(root), nil
+}
+
+// parseKey zerlegt a[b][0] in a, b und 0.
+func parseKey(key string) ([]string, error) {
+	name, rest, _ := strings.Cut(key, "[")
+	path := []string{name}
+	if rest == "" {
+		return path, nil
+	}
+	for _, segment := range strings.Split(strings.TrimSuffix(rest, "]"), "][") {
+		if strings.ContainsAny(segment, "[]") {
+			return nil, errors.New("ungültiger Schlüssel " + key)
+		}
+		path = append(path, segment)
+	}
+	return path, nil
+}
+
+// indexedLists wandelt Objekte mit den Schlüsseln 0..n-1 in Listen.
+func indexedLists(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = indexedLists(item)
+		}
+		if len(v) == 0 {
+			return v
+		}
+		items := make([]any, len(v))
+		for key, item := range v {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) || strconv.Itoa(i) != key {
+				return v
+			}
+			items[i] = item
+		}
+		return items
+	case []any:
+		for i, item := range v {
+			v[i] = indexedLists(item)
+		}
+	}
+	return value
+}
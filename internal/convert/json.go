@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+func init() {
+	Register(JSON, Format{MIME: "application/json", New: func(Options) Codec { return jsonCodec{} }})
+}
+
+type jsonCodec struct{}
+
+// Decode behält Zahlen als json.Number, damit große Ganzzahlen erhalten bleiben.
+func (jsonCodec) Decode(payload []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("mehr als ein JSON-Wert")
+	}
+	return value, nil
+}
+
+// Encode schreibt Schlüssel sortiert und ohne HTML-Maskierung.
+func (jsonCodec) Encode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,41 @@
+package convert
+
+import (
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+func init() {
+	Register(MsgPack, Format{MIME: "application/msgpack", New: func(Options) Codec { return newMsgPackCodec() }})
+}
+
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgPackCodec() msgpackCodec {
+	h := &codec.MsgpackHandle{}
+	// Aktuelle Spezifikation mit str8 und bin, Schlüssel sortiert
+	h.WriteExt = true
+	h.Canonical = true
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	return msgpackCodec{handle: h}
+}
+
+func (c msgpackCodec) Decode(payload []byte) (any, error) {
+	var value any
+	if err := codec.NewDecoderBytes(payload, c.handle).Decode(&value); err != nil {
+		return nil, err
+	}
+	return Normalize(value), nil
+}
+
+func (c msgpackCodec) Encode(value any) ([]byte, error) {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, c.handle).Encode(native(value)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,265 @@
+package convert
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+func init() {
+	Register(XML, Format{MIME: "application/xml", New: func(opts Options) Codec { return xmlCodec{opts.XML} }})
+}
+
+// XMLOptions legen die Abbildung zwischen Objekten und XML fest.
+type XMLOptions struct {
+	// Wurzelelement (Standard: data)
+	Root string `mapstructure:"root"`
+	// Element für Einträge von Listen ohne eigenen Namen (Standard: item)
+	Item string `mapstructure:"item"`
+	// Schlüssel mit diesem Präfix werden zu Attributen (Standard: @)
+	AttributePrefix string `mapstructure:"attribute_prefix"`
+	// Schlüssel für den Text eines Elements mit Attributen oder Kindern (Standard: #text)
+	TextKey string `mapstructure:"text_key"`
+	// Standard-Namespace und Präfixe (Präfix → URI) am Wurzelelement
+	Namespace  string            `mapstructure:"namespace"`
+	Namespaces map[string]string `mapstructure:"namespaces"`
+}
+
+func (o XMLOptions) root() string            { return cmp.Or(o.Root, "data") }
+func (o XMLOptions) item() string            { return cmp.Or(o.Item, "item") }
+func (o XMLOptions) attributePrefix() string { return cmp.Or(o.AttributePrefix, "@") }
+func (o XMLOptions) textKey() string         { return cmp.Or(o.TextKey, "#text") }
+
+type xmlCodec struct {
+	opts XMLOptions
+}
+
+// Encode schreibt den Wert unter das Wurzelelement. Kinder und Attribute stehen
+// in der Reihenfolge ihrer Schlüssel, Listen werden zu wiederholten Elementen.
+func (c xmlCodec) Encode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	root := c.opts.root()
+	if err := CheckName(root); err != nil {
+		return nil, err
+	}
+	buf.WriteString("<" + root)
+	if c.opts.Namespace != "" {
+		writeAttribute(&buf, "xmlns", c.opts.Namespace)
+	}
+	for _, prefix := range slices.Sorted(maps.Keys(c.opts.Namespaces)) {
+		writeAttribute(&buf, "xmlns:"+prefix, c.opts.Namespaces[prefix])
+	}
+
+	if items, ok := value.([]any); ok {
+		buf.WriteString(">")
+		for _, item := range items {
+			if err := c.element(&buf, c.opts.item(), item); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := c.content(&buf, value); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</" + root + ">")
+	return buf.Bytes(), nil
+}
+
+func (c xmlCodec) element(buf *bytes.Buffer, name string, value any) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	if items, ok := value.([]any); ok {
+		for _, item := range items {
+			// Listen in Listen erhalten Einträge mit dem Element für Listeneinträge
+			if nested, ok := item.([]any); ok {
+				buf.WriteString("<" + name + ">")
+				for _, entry := range nested {
+					if err := c.element(buf, c.opts.item(), entry); err != nil {
+						return err
+					}
+				}
+				buf.WriteString("</" + name + ">")
+				continue
+			}
+			if err := c.element(buf, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	buf.WriteString("<" + name)
+	if err := c.content(buf, value); err != nil {
+		return err
+	}
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// content schreibt Attribute, schließt das Starttag und schreibt Text und Kinder.
+func (c xmlCodec) content(buf *bytes.Buffer, value any) error {
+	m, ok := value.(map[string]any)
+	if !ok {
+		text, err := scalar(value)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(">")
+		return xml.EscapeText(buf, []byte(text))
+	}
+
+	prefix := c.opts.attributePrefix()
+	keys := sortedKeys(m)
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if err := CheckName(name); err != nil {
+			return err
+		}
+		text, err := scalar(m[key])
+		if err != nil {
+			return err
+		}
+		writeAttribute(buf, name, text)
+	}
+	buf.WriteString(">")
+
+	if text, ok := m[c.opts.textKey()]; ok {
+		s, err := scalar(text)
+		if err != nil {
+			return err
+		}
+		if err := xml.EscapeText(buf, []byte(s)); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) || key == c.opts.textKey() {
+			continue
+		}
+		if err := c.element(buf, key, m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAttribute(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" " + name + `="`)
+	xml.EscapeText(buf, []byte(value))
+	buf.WriteString(`"`)
+}
+
+// CheckName prüft, ob ein Schlüssel als Element- oder Attributname taugt.
+func CheckName(name string) error {
+	if name == "" {
+		return errors.New("leerer Elementname")
+	}
+	for i, r := range name {
+		letter := r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r > 0x7f
+		if letter || (i > 0 && (r == '-' || r == '.' || (r >= '0' && r <= '9'))) {
+			continue
+		}
+		return fmt.Errorf("ungültiger Elementname %q", name)
+	}
+	return nil
+}
+
+// Decode liest den Inhalt des Wurzelelements. Wiederholte Elemente werden zu
+// Listen, Attribute erhalten das Präfix, Text liegt bei Elementen ohne Kinder
+// und Attribute direkt als Zeichenkette vor. Elemente eines unter namespaces
+// konfigurierten Namespaces behalten dessen Präfix.
+func (c xmlCodec) Decode(payload []byte) (any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(payload))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, errors.New("Wurzelelement fehlt")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := c.decodeElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			// Eine Liste ohne eigenen Namen direkt unter der Wurzel
+			if m, ok := value.(map[string]any); ok && len(m) == 1 {
+				if items, ok := m[c.opts.item()]; ok {
+					if list, ok := items.([]any); ok {
+						return list, nil
+					}
+					return []any{items}, nil
+				}
+			}
+			return value, nil
+		}
+	}
+}
+
+func (c xmlCodec) decodeElement(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	m := make(map[string]any)
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		m[c.opts.attributePrefix()+c.name(attr.Name)] = attr.Value
+	}
+
+	var text strings.Builder
+	children := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			children = true
+			child, err := c.decodeElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			name := c.name(t.Name)
+			switch existing := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []any:
+				m[name] = append(existing, child)
+			default:
+				m[name] = []any{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(m) == 0 && !children {
+				return text.String(), nil
+			}
+			if s := strings.TrimSpace(text.String()); s != "" {
+				m[c.opts.textKey()] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// name liefert den Namen mit dem konfigurierten Präfix seines Namespaces.
+func (c xmlCodec) name(name xml.Name) string {
+	for prefix, uri := range c.opts.Namespaces {
+		if uri == name.Space {
+			return prefix + ":" + name.Local
+		}
+	}
+	return name.Local
+}
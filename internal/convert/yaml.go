@@ -0,0 +1,22 @@
+package convert
+
+import "gopkg.in/yaml.v3"
+
+func init() {
+	Register(YAML, Format{MIME: "application/yaml", New: func(Options) Codec { return yamlCodec{} }})
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Decode(payload []byte) (any, error) {
+	var value any
+	if err := yaml.Unmarshal(payload, &value); err != nil {
+		return nil, err
+	}
+	return Normalize(value), nil
+}
+
+// Encode schreibt Maps mit sortierten Schlüsseln.
+func (yamlCodec) Encode(value any) ([]byte, error) {
+	return yaml.Marshal(native(value))
+}
@@ -2,14 +2,27 @@ package data
 
 import (
 	"html/template"
+	texttemplate "text/template"
+	"time"
 
 	"djp.chapter42.de/a/internal/auth"
+	"djp.chapter42.de/a/internal/blackout"
+	"djp.chapter42.de/a/internal/breaker"
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/ratelimit"
 )
 
 type WavelyConfig struct {
-	Port    string        `mapstructure:"port"`
-	Debug   bool          `mapstructure:"debug"`
-	Current CurrentConfig `mapstructure:"current"`
+	Port     string         `mapstructure:"port"`
+	Debug    bool           `mapstructure:"debug"`
+	Shutdown ShutdownConfig `mapstructure:"shutdown"`
+	Current  CurrentConfig  `mapstructure:"current"`
+}
+
+type ShutdownConfig struct {
+	// Frist, innerhalb der laufende Schreibvorgänge beim Herunterfahren abgeschlossen werden
+	GracePeriod time.Duration `mapstructure:"grace_period"`
 }
 
 type CurrentConfig struct {
@@ -20,15 +33,63 @@ type CurrentConfig struct {
 	Auth        auth.AuthConfig `mapstructure:"auth"`
 	Repititions int             `mapstructure:"repetitions"`
 	MinWorkers  int             `mapstructure:"min_workers"`
-	MaxWorkers  int             `mapstructure:"min_workers"`
+	MaxWorkers  int             `mapstructure:"max_workers"`
+
+	// Umgang mit mehrfach eingereichten UIDs: reject, replace oder queue
+	DuplicatePolicy string            `mapstructure:"duplicate_policy"`
+	Idempotency     IdempotencyConfig `mapstructure:"idempotency"`
+	Batch           BatchConfig       `mapstructure:"batch"`
+	Queue           QueueConfig       `mapstructure:"queue"`
+	Restore         RestoreConfig     `mapstructure:"restore"`
+
+	// Abbildung des Payloads auf content_type (Wurzelelement, Attribute, ...)
+	Convert convert.Options `mapstructure:"convert"`
+	// Template, das den Payload vor dem Schreiben umformt (.Value, .Revision, ...)
+	Transform string `mapstructure:"transform"`
+
+	// Schritte eines Versuchs; ohne Pipeline gilt revision → check → write
+	Pipeline []StepConfig `mapstructure:"pipeline"`
+	// Prüfung, ob das Target den geschriebenen Payload übernommen hat
+	Verify VerifyConfig `mapstructure:"verify"`
+	// Sperren des Targets während eines Versuchs
+	Lock LockConfig `mapstructure:"lock"`
+
+	// Wiederkehrende Abfragen mit Änderungserkennung
+	Pollers []PollerConfig `mapstructure:"pollers"`
+
+	// Sperrzeiten, in denen das Target nicht angesprochen wird
+	Blackout blackout.Config `mapstructure:"blackout"`
+
+	// Gemeinsames Rate-Limit aller Worker gegen das Target
+	RateLimit ratelimit.Config `mapstructure:"rate_limit"`
+
+	// Circuit Breaker, der bei Ausfall des Targets alle Jobs parkt
+	CircuitBreaker breaker.Config `mapstructure:"circuit_breaker"`
+
+	// Seed für Phasen und Jitter der Backoffs (0 = zufällig)
+	Seed uint64 `mapstructure:"seed"`
 
 	// Caching vorbereiteter Templates
 	ParsedCheckTpl    *template.Template
 	ParsedRevisionTpl *template.Template
 	ParsedWriteTpl    *template.Template
+	ParsedTransform   *texttemplate.Template
 
 	// Authentication provider
 	AuthProvider auth.AuthProvider
+
+	// Aus Blackout erzeugter Kalender (nil ohne Sperrzeiten)
+	Calendar *blackout.Calendar
+
+	// Aus RateLimit erzeugter Limiter (nil ohne Limit)
+	Limiter *ratelimit.Limiter
+
+	// Aus CircuitBreaker erzeugter Breaker (nil ohne Schwellen)
+	Breaker *breaker.Breaker
+
+	// Uhr und Zufallsquelle der Verarbeitung (nil: Systemuhr bzw. globale Quelle)
+	Clock  clock.Clock
+	Random *clock.Random
 }
 
 type EndpointConfig struct {
@@ -36,3 +97,64 @@ type EndpointConfig struct {
 	Revision string `mapstructure:"revision"`
 	Write    string `mapstructure:"write"`
 }
+
+type IdempotencyConfig struct {
+	Window time.Duration `mapstructure:"window"`
+}
+
+type QueueConfig struct {
+	Capacity int `mapstructure:"capacity"`
+	// Ist eine Datei gesetzt, werden Jobs bei voller Queue dorthin ausgelagert
+	SpillFile  string `mapstructure:"spill_file"`
+	SpillLimit int    `mapstructure:"spill_limit"`
+	// Gewichte der Prioritätsklassen (high, normal, low)
+	Weights map[string]int `mapstructure:"weights"`
+}
+
+type PollerConfig struct {
+	Name     string        `mapstructure:"name" json:"name"`
+	Endpoint string        `mapstructure:"endpoint" json:"endpoint"`
+	UID      string        `mapstructure:"uid" json:"uid,omitempty"`
+	Interval time.Duration `mapstructure:"interval" json:"interval,omitempty"`
+	Cron     string        `mapstructure:"cron" json:"cron,omitempty"`
+	// Anteil des Intervalls, um den die Abfragen sinusförmig gestreut werden
+	Spread float64    `mapstructure:"spread" json:"spread,omitempty"`
+	Detect string     `mapstructure:"detect" json:"detect,omitempty"` // hash, diff
+	Sink   SinkConfig `mapstructure:"sink" json:"sink"`
+}
+
+type SinkConfig struct {
+	Type string `mapstructure:"type" json:"type"` // webhook, file, sse
+	URL  string `mapstructure:"url" json:"url,omitempty"`
+	Path string `mapstructure:"path" json:"path,omitempty"`
+}
+
+const (
+	DetectHash = "hash"
+	DetectDiff = "diff"
+
+	SinkWebhook = "webhook"
+	SinkFile    = "file"
+	SinkSSE     = "sse"
+)
+
+type RestoreConfig struct {
+	// Wiederhergestellte Jobs pro Sekunde beim Start (0 = unbegrenzt)
+	Rate float64 `mapstructure:"rate"`
+}
+
+type BatchConfig struct {
+	Mode     string `mapstructure:"mode"` // atomic, best_effort
+	MaxItems int    `mapstructure:"max_items"`
+}
+
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+const (
+	DuplicateReject  = "reject"
+	DuplicateReplace = "replace"
+	DuplicateQueue   = "queue"
+)
@@ -1,8 +1,101 @@
 package data
 
-// Job definiert die Struktur eines zu verarbeitenden Jobs.
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job definiert die Struktur eines zu verarbeitenden Jobs. Data ist der Payload
+// in Base64, unabhängig davon, wie er eingereicht wurde.
 type Job struct {
 	UID         string `json:"uid,omitempty"`
 	Data        string `json:"data,omitempty"`
 	ContentType string `json:"content_type,omitempty"`
+	Priority    string `json:"priority,omitempty"`
+
+	// RunAt plant den ersten Versuch auf einen festen Zeitpunkt, NotBefore legt nur
+	// den frühesten Zeitpunkt fest (danach greift der normale Backoff).
+	RunAt     time.Time `json:"run_at,omitzero"`
+	NotBefore time.Time `json:"not_before,omitzero"`
+}
+
+// Prioritäten eines Jobs; ohne Angabe gilt "normal".
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// DueAt liefert den Zeitpunkt, ab dem der Job bearbeitet werden darf.
+func (j Job) DueAt() time.Time {
+	if j.RunAt.After(j.NotBefore) {
+		return j.RunAt
+	}
+	return j.NotBefore
+}
+
+// Kodierungen von data beim Einreichen eines Jobs.
+const (
+	EncodingBase64 = "base64"
+	EncodingJSON   = "json"
+)
+
+// UnmarshalJSON nimmt data als Base64-Zeichenkette (encoding base64 oder ohne
+// Angabe) oder als beliebiges JSON (encoding json oder ohne Angabe bei
+// Objekten, Listen, Zahlen usw.) entgegen. JSON wird unverändert übernommen und
+// erhält content_type json.
+func (j *Job) UnmarshalJSON(b []byte) error {
+	type plain Job
+	aux := struct {
+		*plain
+		Data     json.RawMessage `json:"data"`
+		Encoding string          `json:"encoding"`
+	}{plain: (*plain)(j)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	raw := bytes.TrimSpace(aux.Data)
+	if len(raw) == 0 || string(raw) == "null" {
+		if aux.Encoding != "" && aux.Encoding != EncodingBase64 && aux.Encoding != EncodingJSON {
+			return errors.New("unbekanntes encoding: " + aux.Encoding)
+		}
+		return nil
+	}
+
+	encoding := aux.Encoding
+	if encoding == "" {
+		// Zeichenketten ohne Angabe sind Base64 wie bisher
+		encoding = EncodingJSON
+		if raw[0] == '"' {
+			encoding = EncodingBase64
+		}
+	}
+
+	switch encoding {
+	case EncodingBase64:
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return errors.New("data muss bei encoding base64 eine Zeichenkette sein")
+		}
+		if aux.Encoding != "" {
+			if _, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+				return errors.New("data ist kein gültiges Base64")
+			}
+		}
+		j.Data = encoded
+	case EncodingJSON:
+		if j.ContentType != "" && j.ContentType != EncodingJSON {
+			return errors.New("data als JSON erfordert content_type json, ist " + j.ContentType)
+		}
+		j.Data = base64.StdEncoding.EncodeToString(raw)
+		j.ContentType = cmp.Or(j.ContentType, EncodingJSON)
+	default:
+		return errors.New("unbekanntes encoding: " + aux.Encoding)
+	}
+	return nil
 }
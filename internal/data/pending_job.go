@@ -1,10 +1,41 @@
 package data
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
 
 // PendingJob enthält den Job und zusätzliche Informationen für die Verarbeitung.
 type PendingJob struct {
-	Job       Job
-	CreatedAt time.Time
-	Attempts  int
-}
\ No newline at end of file
+	ID             string
+	Job            Job
+	CreatedAt      time.Time
+	Attempts       int
+	IdempotencyKey string `json:",omitempty"`
+
+	// Target und einsendender Client, für die faire Verteilung der Worker
+	Target string `json:",omitempty"`
+	Client string `json:",omitempty"`
+
+	// Version wird bei jedem Ersetzen des Payloads erhöht (Policy "replace").
+	Version int `json:",omitempty"`
+	// After verweist auf den Job mit derselben UID, der vorher abgeschlossen sein muss (Policy "queue").
+	After string `json:",omitempty"`
+
+	// Phase und Jitter des Backoffs, damit ein wiederhergestellter Job seine Kurve fortsetzt
+	PhaseShift float64 `json:",omitempty"`
+	Jitter     float64 `json:",omitempty"`
+
+	// Gesamte Wartezeit am Rate-Limiter des Targets
+	RateLimitWait time.Duration `json:",omitempty"`
+}
+
+// NewJobID erzeugt eine zufällige ID für einen neuen Job.
+func NewJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
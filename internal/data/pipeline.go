@@ -0,0 +1,119 @@
+package data
+
+import (
+	"text/template"
+	"time"
+)
+
+// StepConfig ist ein Schritt der Pipeline eines Targets: ein HTTP-Aufruf mit
+// Templates für Endpunkt, Header und Body. Die extrahierten Ausgaben stehen
+// folgenden Schritten unter .Steps.<name>.<ausgabe> zur Verfügung.
+type StepConfig struct {
+	Name     string            `mapstructure:"name"`
+	Method   string            `mapstructure:"method"` // Standard: GET
+	Endpoint string            `mapstructure:"endpoint"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Body     string            `mapstructure:"body"`
+
+	// Ausgaben des Schritts mit ihrer Quelle: status, body, body.<pfad> oder header.<name>
+	Extract map[string]string `mapstructure:"extract"`
+	Success SuccessConfig     `mapstructure:"success"`
+
+	// retry: der Versuch scheitert, continue: mit dem nächsten Schritt fortfahren
+	OnFailure string `mapstructure:"on_failure"`
+	// Auch nach einem gescheiterten Schritt ausführen, z.B. zum Entsperren
+	Always bool `mapstructure:"always"`
+	// Limit aus rate_limit.endpoints (Standard: Name des Schritts)
+	RateLimit string `mapstructure:"rate_limit"`
+
+	// Vorbereitete Templates: endpoint, body, condition und header:<name>
+	Parsed *template.Template
+}
+
+type SuccessConfig struct {
+	// Erfolgreiche Statuscodes (leer: 2xx)
+	Status []int `mapstructure:"status"`
+	// Template, das nach der Extraktion "true" ergeben muss
+	Condition string `mapstructure:"condition"`
+}
+
+const (
+	OnFailureRetry    = "retry"
+	OnFailureContinue = "continue"
+)
+
+// VerifyConfig beschreibt die Prüfung nach dem Schreiben: die Ressource wird
+// erneut gelesen und mit dem geschriebenen Payload verglichen.
+type VerifyConfig struct {
+	Mode     string `mapstructure:"mode"` // field, checksum, revision (leer: keine Prüfung)
+	Endpoint string `mapstructure:"endpoint"`
+	// Quelle in der Antwort wie bei extract, z.B. body.value oder header.etag
+	Field string `mapstructure:"field"`
+	// Erwarteter Wert als Template (Standard: derselbe Pfad im Payload bzw.
+	// dessen Prüfsumme)
+	Expected  string `mapstructure:"expected"`
+	Algorithm string `mapstructure:"algorithm"` // sha256, md5
+
+	// Lesevorgänge je Schreibversuch, bevor der Versuch als gescheitert gilt
+	Attempts int           `mapstructure:"attempts"`
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Vorbereitete Templates: endpoint und expected
+	Parsed *template.Template
+}
+
+const (
+	VerifyField    = "field"
+	VerifyChecksum = "checksum"
+	VerifyRevision = "revision"
+
+	ChecksumSHA256 = "sha256"
+	ChecksumMD5    = "md5"
+)
+
+// LockConfig beschreibt das Sperrprotokoll eines Targets: vor dem Schreiben
+// wird eine Lease mit Token erworben, bei langen Versuchen verlängert und
+// danach immer freigegeben. Templates erreichen das Token über .Lock.Token.
+type LockConfig struct {
+	Acquire RequestConfig `mapstructure:"acquire"` // Standard: POST
+	Renew   RequestConfig `mapstructure:"renew"`   // Standard: PUT
+	Release RequestConfig `mapstructure:"release"` // Standard: DELETE
+
+	// Quelle des Tokens in der Antwort auf acquire wie bei extract
+	Token string `mapstructure:"token"`
+	// Header, in dem das Token beim Schreiben mitgeschickt wird
+	Header string `mapstructure:"header"`
+	// Laufzeit der Lease; nach der Hälfte wird sie über renew verlängert
+	Lease time.Duration `mapstructure:"lease"`
+}
+
+// Enabled meldet, ob das Target gesperrt werden muss.
+func (l LockConfig) Enabled() bool {
+	return l.Acquire.Endpoint != ""
+}
+
+// RequestConfig ist ein einzelner Aufruf des Targets außerhalb der Pipeline.
+type RequestConfig struct {
+	Method   string            `mapstructure:"method"`
+	Endpoint string            `mapstructure:"endpoint"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Body     string            `mapstructure:"body"`
+	// Erfolgreiche Statuscodes (leer: 2xx)
+	Status []int `mapstructure:"status"`
+
+	// Vorbereitete Templates wie bei StepConfig
+	Parsed *template.Template
+}
+
+// Step liefert den Aufruf als Pipeline-Schritt.
+func (r RequestConfig) Step(name string) StepConfig {
+	return StepConfig{
+		Name:     name,
+		Method:   r.Method,
+		Endpoint: r.Endpoint,
+		Headers:  r.Headers,
+		Body:     r.Body,
+		Success:  SuccessConfig{Status: r.Status},
+		Parsed:   r.Parsed,
+	}
+}
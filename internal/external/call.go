@@ -0,0 +1,92 @@
+package external
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"io"
+	"net/http"
+
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Request ist ein frei definierter Aufruf des Targets, z.B. ein Pipeline-Schritt.
+type Request struct {
+	Method string
+	// Pfad relativ zu base_url
+	Endpoint string
+	Header   http.Header
+	Body     []byte
+	// Endpunkt für Rate-Limit und Metriken
+	Limit string
+}
+
+// Response ist die vollständig gelesene Antwort eines Aufrufs.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Call führt einen Aufruf mit Authentifizierung, Rate-Limit und Circuit Breaker
+// aus. Jeder Statuscode gilt als Antwort; die Bewertung übernimmt der Aufrufer.
+// Wartezeit am Rate-Limiter und Abbruch richten sich nach dem mit Attach
+// angemeldeten Versuch von job; Aufrufe ohne laufenden Versuch (z.B. Verlängern
+// einer Sperre) übergeben nil.
+func Call(r Request, job *data.Job, currentCfg *data.CurrentConfig) (*Response, error) {
+	if currentCfg.BaseURL == "" {
+		return nil, errors.New("base_url ist nicht in der Konfiguration definiert")
+	}
+
+	req, err := http.NewRequestWithContext(callContext(job), r.Method, currentCfg.BaseURL+r.Endpoint, bytes.NewReader(r.Body))
+	if err != nil {
+		logger.Log.Warn("Error while generating request:", zap.Error(err))
+		return nil, err
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	if len(r.Body) > 0 && req.Header.Get("Content-Type") == "" && mimeType(job, currentCfg) != "" {
+		req.Header.Set("Content-Type", mimeType(job, currentCfg))
+	}
+	req.Header.Set("User-Agent", "Wavely/1.0")
+
+	if currentCfg.AuthProvider != nil {
+		auth_header, err := currentCfg.AuthProvider.GetAuthHeader()
+		if err != nil {
+			logger.Log.Warn("Error while generating AuthHeaders:", zap.Error(err))
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth_header)
+	}
+
+	if err := acquire(currentCfg, job, r.Limit); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	record(currentCfg, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// mimeType liefert den Content-Type des Payloads: den content_type des Targets,
+// in den der Payload umgewandelt wird, sonst den des Jobs.
+func mimeType(job *data.Job, currentCfg *data.CurrentConfig) string {
+	if job == nil {
+		return convert.MIME(currentCfg.ContentType)
+	}
+	return convert.MIME(cmp.Or(currentCfg.ContentType, job.ContentType))
+}
@@ -2,11 +2,15 @@ package external
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"djp.chapter42.de/a/internal/data"
 	"djp.chapter42.de/a/internal/logger"
@@ -15,12 +19,18 @@ import (
 )
 
 func WriteCheck(job *data.Job, currentCfg *data.CurrentConfig) (bool, error) {
-	checkURL, err := urlBuilder(currentCfg, job, "check")
+	return WriteCheckRevision(job, job.UID, currentCfg)
+}
+
+// WriteCheckRevision prüft wie WriteCheck, rendert den Endpunkt aber mit der
+// Revision als UID, ohne den Job zu verändern.
+func WriteCheckRevision(job *data.Job, revision string, currentCfg *data.CurrentConfig) (bool, error) {
+	checkURL, err := urlBuilder(currentCfg, atRevision(job, revision), "check")
 	if err != nil {
 		return false, err
 	}
 
-	req, err := http.NewRequest(http.MethodGet, checkURL, nil)
+	req, err := http.NewRequestWithContext(callContext(job), http.MethodGet, checkURL, nil)
 	if err != nil {
 		logger.Log.Warn("Error while generating request:", zap.Error(err))
 		return false, err
@@ -37,8 +47,13 @@ func WriteCheck(job *data.Job, currentCfg *data.CurrentConfig) (bool, error) {
 		req.Header.Set("Authorization", auth_header)
 	}
 
+	if err := acquire(currentCfg, job, "check"); err != nil {
+		return false, err
+	}
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
+	record(currentCfg, resp, err)
 	if err != nil {
 		return false, err
 	}
@@ -57,7 +72,13 @@ func WriteCheck(job *data.Job, currentCfg *data.CurrentConfig) (bool, error) {
 }
 
 func WriteData(job *data.Job, data string, currentCfg *data.CurrentConfig) error {
-	checkURL, err := urlBuilder(currentCfg, job, "check")
+	return WriteDataWithHeader(job, job.UID, data, nil, currentCfg)
+}
+
+// WriteDataWithHeader schreibt wie WriteData an die Revision und schickt
+// zusätzliche Header mit, z.B. das Token einer Sperre.
+func WriteDataWithHeader(job *data.Job, revision, data string, header http.Header, currentCfg *data.CurrentConfig) error {
+	writeURL, err := urlBuilder(currentCfg, atRevision(job, revision), "write")
 	if err != nil {
 		return err
 	}
@@ -68,20 +89,15 @@ func WriteData(job *data.Job, data string, currentCfg *data.CurrentConfig) error
 		return err
 	}
 
-	var contentType string
-	switch job.ContentType {
-	case "json":
-		contentType = "application/json"
-	case "xml":
-		contentType = "application/xml"
-	}
-
-	req, err := http.NewRequest(http.MethodPut, checkURL, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(callContext(job), http.MethodPut, writeURL, bytes.NewReader(payload))
 	if err != nil {
 		logger.Log.Error("Error while generating request:", zap.Error(err))
 		return err
 	}
-	req.Header.Set("Content-Type", contentType)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", mimeType(job, currentCfg))
 	req.Header.Set("User-Agent", "Wavely/1.0")
 
 	if currentCfg.AuthProvider != nil {
@@ -93,8 +109,13 @@ func WriteData(job *data.Job, data string, currentCfg *data.CurrentConfig) error
 		req.Header.Set("Authorization", auth_header)
 	}
 
+	if err := acquire(currentCfg, job, "write"); err != nil {
+		return err
+	}
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
+	record(currentCfg, resp, err)
 	if err != nil {
 		logger.Log.Warn("Error while calling the write api:", zap.Error(err))
 		return err
@@ -116,7 +137,7 @@ func LatestRevision(job *data.Job, currentCfg *data.CurrentConfig) (string, erro
 		return "", err
 	}
 
-	req, err := http.NewRequest(http.MethodGet, revisionURL, nil)
+	req, err := http.NewRequestWithContext(callContext(job), http.MethodGet, revisionURL, nil)
 	if err != nil {
 		logger.Log.Warn("Error while generating request:", zap.Error(err))
 		return "", err
@@ -133,8 +154,13 @@ func LatestRevision(job *data.Job, currentCfg *data.CurrentConfig) (string, erro
 		req.Header.Set("Authorization", auth_header)
 	}
 
+	if err := acquire(currentCfg, job, "revision"); err != nil {
+		return "", err
+	}
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
+	record(currentCfg, resp, err)
 	if err != nil {
 		return "", err
 	}
@@ -158,14 +184,148 @@ func LatestRevision(job *data.Job, currentCfg *data.CurrentConfig) (string, erro
 	}
 }
 
+// Fetch ruft eine URL des Targets per GET ab, z.B. für wiederkehrende Abfragen.
+func Fetch(url string, currentCfg *data.CurrentConfig) ([]byte, int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		logger.Log.Warn("Error while generating request:", zap.Error(err))
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Wavely/1.0")
+
+	if currentCfg.AuthProvider != nil {
+		auth_header, err := currentCfg.AuthProvider.GetAuthHeader()
+		if err != nil {
+			logger.Log.Warn("Error while generating AuthHeaders:", zap.Error(err))
+			return nil, 0, err
+		}
+		req.Header.Set("Authorization", auth_header)
+	}
+
+	if err := acquire(currentCfg, nil, "fetch"); err != nil {
+		return nil, 0, err
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	record(currentCfg, resp, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// Laufende Versuche mit ihrem Kontext und den Wartezeiten am Rate-Limiter. Die
+// Wartezeiten gehören zum Versuch und nicht zum Job, daher werden sie hier statt
+// in data.Job gesammelt.
+type attempt struct {
+	wait          context.Context
+	calls         context.Context
+	rateLimitWait time.Duration
+}
+
+var (
+	attempts      = make(map[*data.Job]*attempt)
+	attemptsMutex sync.Mutex
+)
+
+// Attach meldet job für einen Versuch an: Wartezeiten am Rate-Limiter enden mit
+// wait und werden job zugerechnet, laufende Aufrufe des Targets enden mit calls.
+func Attach(job *data.Job, wait, calls context.Context) {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+	attempts[job] = &attempt{wait: wait, calls: calls}
+}
+
+// Detach meldet job ab und verwirft noch nicht abgeholte Wartezeiten.
+func Detach(job *data.Job) {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+	delete(attempts, job)
+}
+
+// acquire wartet auf das Rate-Limit des Targets und vermerkt die Wartezeit für
+// den Versuch. Aufrufe ohne angemeldeten Versuch warten ohne Abbruch.
+func acquire(currentCfg *data.CurrentConfig, job *data.Job, endpoint string) error {
+	attemptsMutex.Lock()
+	a := attempts[job]
+	attemptsMutex.Unlock()
+
+	ctx := context.Background()
+	if a != nil {
+		ctx = a.wait
+	}
+	wait, err := currentCfg.Limiter.Acquire(ctx, endpoint)
+	if err != nil {
+		return fmt.Errorf("Warten auf das Rate-Limit abgebrochen: %w", err)
+	}
+	if a != nil && wait > 0 {
+		attemptsMutex.Lock()
+		a.rateLimitWait += wait
+		attemptsMutex.Unlock()
+	}
+	return nil
+}
+
+// callContext liefert den Kontext für Aufrufe im Versuch von job. Aufrufe ohne
+// angemeldeten Versuch werden nicht abgebrochen.
+func callContext(job *data.Job) context.Context {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	if a, ok := attempts[job]; ok {
+		return a.calls
+	}
+	return context.Background()
+}
+
+// TakeRateLimitWait liefert die seit dem letzten Aufruf für job angefallene
+// Wartezeit am Rate-Limiter und setzt sie zurück.
+func TakeRateLimitWait(job *data.Job) time.Duration {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	a, ok := attempts[job]
+	if !ok {
+		return 0
+	}
+	wait := a.rateLimitWait
+	a.rateLimitWait = 0
+	return wait
+}
+
+// record meldet das Ergebnis eines Aufrufs an den Circuit Breaker des Targets.
+// Transportfehler, 5xx und 429 gelten als Fehlschlag.
+func record(currentCfg *data.CurrentConfig, resp *http.Response, err error) {
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+	currentCfg.Breaker.Record(failed)
+}
+
+// atRevision liefert für die Endpunkt-Templates eine Kopie von job mit der
+// Revision als UID. Rate-Limit und Abbruch bleiben beim Versuch des Jobs.
+func atRevision(job *data.Job, revision string) *data.Job {
+	target := *job
+	target.UID = revision
+	return &target
+}
+
 func urlBuilder(currentCfg *data.CurrentConfig, job *data.Job, ep string) (string, error) {
 	var err error
 	var endpoint string
 
 	baseURL := currentCfg.BaseURL
 	if baseURL == "" {
-		logger.Log.Fatal("baseURL ist nicht in der Konfiguration definiert")
-		return "", nil
+		return "", errors.New("base_url ist nicht in der Konfiguration definiert")
 	}
 
 	switch ep {
@@ -180,8 +340,8 @@ func urlBuilder(currentCfg *data.CurrentConfig, job *data.Job, ep string) (strin
 		return "", err
 	}
 	if err != nil {
-		logger.Log.Warn("Fehler beim Rendern des Endpunktes:", zap.Error(err))
-		return "", err
+		logger.Log.Warn("Fehler beim Rendern des Endpunktes:", zap.String("endpoint", ep), zap.Error(err))
+		return "", fmt.Errorf("endpoints.%s: %w", ep, err)
 	}
 
 	fullURL := baseURL + endpoint
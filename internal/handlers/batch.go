@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/control"
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/processor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const DefaultBatchMaxItems = 1000
+
+const (
+	batchAccepted = "accepted"
+	batchRejected = "rejected"
+)
+
+// batchItemResult ist das Ergebnis für einen einzelnen Eintrag eines Batches.
+type batchItemResult struct {
+	Index  int    `json:"index"`
+	UID    string `json:"uid,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Code   int    `json:"code"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewBatchJobHandler nimmt mehrere Jobs als JSON-Array oder NDJSON entgegen und
+// liefert pro Eintrag ein Ergebnis. Der Modus (atomic, best_effort) kommt aus der
+// Konfiguration und kann per ?mode= überschrieben werden.
+func NewBatchJobHandler(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		items, err := readBatch(c)
+		if err != nil {
+			logger.Log.Warn("Fehler beim Parsen des Batches:", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ungültiges JSON-Format"})
+			return
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Batch enthält keine Jobs"})
+			return
+		}
+		if limit := batchMaxItems(); len(items) > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Batch enthält zu viele Jobs", "max_items": limit})
+			return
+		}
+
+		mode := batchMode(c.Query("mode"))
+		client := clientID(c)
+		jobs := make([]data.Job, len(items))
+		results := make([]batchItemResult, len(items))
+		invalid := 0
+
+		for i, raw := range items {
+			results[i] = batchItemResult{Index: i}
+			if err := json.Unmarshal(raw, &jobs[i]); err != nil {
+				results[i].reject(http.StatusBadRequest, "Ungültiges JSON-Format")
+				invalid++
+				continue
+			}
+			results[i].UID = jobs[i].UID
+			if err := validateJob(jobs[i]); err != nil {
+				results[i].reject(http.StatusUnprocessableEntity, err.Error())
+				invalid++
+			}
+		}
+
+		if mode == data.BatchAtomic {
+			if invalid > 0 {
+				rejectRemaining(results, "Batch wegen ungültiger Einträge abgelehnt")
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"mode": mode, "results": results})
+				return
+			}
+
+			jobs_mutex.Lock()
+			if status, err := atomicPrecheck(*pending_jobs, jobs); err != nil {
+				jobs_mutex.Unlock()
+				rejectRemaining(results, err.Error())
+				c.JSON(status, gin.H{"mode": mode, "results": results})
+				return
+			}
+			// Die Kapazität wurde vorab geprüft, daher werden alle Jobs angenommen
+			for i, job := range jobs {
+				results[i].apply(admitJob(pending_jobs, job, client, "", "", true))
+			}
+			jobs_mutex.Unlock()
+
+			logger.Log.Info("Batch angenommen:", zap.String("mode", mode), zap.Int("count", len(jobs)))
+			c.JSON(http.StatusAccepted, gin.H{"mode": mode, "results": results})
+			return
+		}
+
+		accepted := 0
+		var retryAfter time.Duration
+		for i, job := range jobs {
+			if results[i].Status == batchRejected {
+				continue
+			}
+
+			jobs_mutex.Lock()
+			result := admitJob(pending_jobs, job, client, "", "", false)
+			jobs_mutex.Unlock()
+
+			results[i].apply(result)
+			retryAfter = max(retryAfter, result.retryAfter)
+			if results[i].Status == batchAccepted {
+				accepted++
+			}
+		}
+
+		logger.Log.Info("Batch verarbeitet:", zap.String("mode", mode), zap.Int("count", len(jobs)), zap.Int("accepted", accepted))
+
+		status := http.StatusAccepted
+		if accepted == 0 {
+			status = http.StatusUnprocessableEntity
+		} else if accepted < len(jobs) {
+			status = http.StatusMultiStatus
+		}
+		if retryAfter > 0 {
+			setRetryAfter(c, retryAfter)
+		}
+		c.JSON(status, gin.H{"mode": mode, "results": results})
+	}
+}
+
+// readBatch liest den Body entweder als JSON-Array oder als NDJSON (ein Job pro Zeile).
+func readBatch(c *gin.Context) ([]json.RawMessage, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+
+	var items []json.RawMessage
+	if len(body) > 0 && body[0] == '[' {
+		err := json.Unmarshal(body, &items)
+		return items, err
+	}
+
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, json.RawMessage(line))
+	}
+	return items, nil
+}
+
+// validateJob prüft einen Job vor der Annahme, einzeln wie im Batch.
+func validateJob(job data.Job) error {
+	if job.UID == "" {
+		return errors.New("uid fehlt")
+	}
+	if job.Data == "" {
+		return errors.New("data fehlt")
+	}
+	if err := checkContentType(job.ContentType); err != nil {
+		return err
+	}
+	switch job.Priority {
+	case "", data.PriorityHigh, data.PriorityNormal, data.PriorityLow:
+	default:
+		return errors.New("unbekannte priority: " + job.Priority)
+	}
+	return nil
+}
+
+// checkContentType lässt nur registrierte Formate zu (ohne Angabe: wie das Target).
+func checkContentType(contentType string) error {
+	if _, ok := convert.Lookup(contentType); contentType != "" && !ok {
+		return errors.New("unbekannter content_type: " + contentType)
+	}
+	return nil
+}
+
+// atomicPrecheck stellt sicher, dass ein atomarer Batch vollständig angenommen
+// werden kann. Der Aufrufer muss jobs_mutex halten.
+func atomicPrecheck(pending_jobs []data.PendingJob, jobs []data.Job) (int, error) {
+	if !control.State.Accepting(targetName()) {
+		return http.StatusServiceUnavailable, errors.New("Target wird geleert, es werden keine neuen Jobs angenommen")
+	}
+	if processor.FreeSlots() < len(jobs) {
+		return http.StatusTooManyRequests, errors.New("nicht genügend freie Plätze in der Queue")
+	}
+	if duplicatePolicy() != data.DuplicateReject {
+		return 0, nil
+	}
+
+	seen := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if seen[job.UID] || lastPendingByUID(pending_jobs, job.UID) >= 0 {
+			return http.StatusConflict, errors.New("Job mit UID " + job.UID + " ist bereits ausstehend")
+		}
+		seen[job.UID] = true
+	}
+	return 0, nil
+}
+
+func (r *batchItemResult) reject(code int, reason string) {
+	r.Status = batchRejected
+	r.Code = code
+	r.Error = reason
+}
+
+func (r *batchItemResult) apply(result admission) {
+	r.Code = result.status
+	if id, ok := result.body["id"].(string); ok {
+		r.ID = id
+	}
+	if result.status >= 300 {
+		r.Status = batchRejected
+		r.Error, _ = result.body["error"].(string)
+		if r.Error == "" {
+			r.Error, _ = result.body["message"].(string)
+		}
+		return
+	}
+	r.Status = batchAccepted
+}
+
+func rejectRemaining(results []batchItemResult, reason string) {
+	for i := range results {
+		if results[i].Status != batchRejected {
+			results[i].reject(http.StatusFailedDependency, reason)
+		}
+	}
+}
+
+func batchMode(override string) string {
+	mode := override
+	if cfg := config.Active(); mode == "" && cfg != nil {
+		mode = cfg.Current.Batch.Mode
+	}
+	if strings.ToLower(mode) == data.BatchAtomic {
+		return data.BatchAtomic
+	}
+	return data.BatchBestEffort
+}
+
+func batchMaxItems() int {
+	cfg := config.Active()
+	if cfg == nil || cfg.Current.Batch.MaxItems <= 0 {
+		return DefaultBatchMaxItems
+	}
+	return cfg.Current.Batch.MaxItems
+}
@@ -0,0 +1,49 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleBatchJobs(t *testing.T) {
+	router := setupRouter()
+	router.POST("/jobs/batch", handlers.NewBatchJobHandler(&jobsMutex, &pendingJobs))
+
+	post := func(url, contentType, body string) (int, []map[string]interface{}) {
+		req, _ := http.NewRequest("POST", url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		var response struct {
+			Results []map[string]interface{} `json:"results"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &response)
+		return resp.Code, response.Results
+	}
+
+	// Best effort: gültige Einträge werden angenommen, ungültige abgelehnt
+	code, results := post("/jobs/batch", "application/x-ndjson", "{\"uid\": \"batch-1\", \"data\": \"dmFsdWU=\"}\n{\"uid\": \"batch-2\"}\n")
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "accepted", results[0]["status"])
+	assert.NotEmpty(t, results[0]["id"])
+	assert.Equal(t, "rejected", results[1]["status"])
+
+	// Atomar: ein ungültiger Eintrag lehnt den ganzen Batch ab
+	code, results = post("/jobs/batch?mode=atomic", "application/json", `[{"uid": "batch-3", "data": "dmFsdWU="}, {"data": "dmFsdWU="}]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, "rejected", results[0]["status"])
+
+	jobsMutex.Lock()
+	assert.Len(t, pendingJobs, 1)
+	pendingJobs = []data.PendingJob{}
+	jobsMutex.Unlock()
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/processor"
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigStatusHandler liefert Hash und Ladezeitpunkt der aktiven Konfiguration
+// sowie den letzten Fehler beim Neuladen.
+func ConfigStatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, configStatus())
+	}
+}
+
+// ReloadConfigHandler lädt die Konfiguration neu. Ist sie ungültig, bleibt die
+// bisherige aktiv und der Fehler wird gemeldet.
+func ReloadConfigHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := config.Reload(config.TriggerAPI); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Konfiguration ungültig, bisherige bleibt aktiv", "status": configStatus()})
+			return
+		}
+		c.JSON(http.StatusOK, configStatus())
+	}
+}
+
+func configStatus() gin.H {
+	return gin.H{"config": config.Status(), "workers": processor.Workers()}
+}
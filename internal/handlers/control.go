@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"djp.chapter42.de/a/internal/control"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/persistence"
+	"djp.chapter42.de/a/internal/processor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func ControlStatusHandler(controls *control.Controls) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, controlStatus(controls))
+	}
+}
+
+// TargetControlHandler pausiert (pause), leert (drain) oder startet (resume)
+// ein Target. Der Zustand wird sofort persistiert.
+func TargetControlHandler(controls *control.Controls) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := c.Param("name")
+		if target != targetName() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target nicht gefunden", "target": target})
+			return
+		}
+
+		action := c.Param("action")
+		switch action {
+		case "pause":
+			controls.Pause(target)
+		case "resume":
+			controls.Resume(target)
+		case "drain":
+			controls.Drain(target)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unbekannte Aktion, erlaubt sind pause, resume und drain", "action": action})
+			return
+		}
+		logger.Log.Info("Betriebszustand des Targets geändert:", zap.String("target", target), zap.String("state", controls.Target(target)))
+
+		saveControls(c, controls)
+	}
+}
+
+// MaintenanceHandler schaltet den globalen Wartungsmodus ein (POST) oder aus (DELETE).
+func MaintenanceHandler(controls *control.Controls, enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		controls.SetMaintenance(enabled)
+		logger.Log.Info("Wartungsmodus geändert:", zap.Bool("enabled", enabled))
+
+		saveControls(c, controls)
+	}
+}
+
+func saveControls(c *gin.Context, controls *control.Controls) {
+	if err := persistence.SaveControls(controls); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Betriebszustand gesetzt, aber nicht gespeichert", "status": controlStatus(controls)})
+		return
+	}
+	c.JSON(http.StatusOK, controlStatus(controls))
+}
+
+func controlStatus(controls *control.Controls) gin.H {
+	name := targetName()
+	return gin.H{
+		"maintenance": controls.Maintenance(),
+		"targets":     gin.H{name: gin.H{"state": controls.Target(name), "in_flight": processor.InFlight()}},
+	}
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/control"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/handlers"
+	"djp.chapter42.de/a/internal/processor"
+	"djp.chapter42.de/a/internal/tmpl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetControls(t *testing.T) {
+	router := setupRouter()
+	router.POST("/jobs", handlers.NewJobHandler(&jobsMutex, &pendingJobs))
+	router.POST("/admin/targets/:name/:action", handlers.TargetControlHandler(control.State))
+
+	var requests int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/revision/") {
+			w.Write([]byte(`{"latest_revision": "paused-uid"}`))
+		}
+	}))
+	defer server.Close()
+
+	config.Config = &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "control-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(config.Config))
+	current := &config.Config.Current
+	defer func() {
+		control.State.Resume(current.Name)
+		config.Config = nil
+	}()
+
+	post := func(url, body string) int {
+		req, _ := http.NewRequest("POST", url, bytes.NewBufferString(body))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	assert.Equal(t, http.StatusNotFound, post("/admin/targets/unknown/pause", ""))
+	assert.Equal(t, http.StatusBadRequest, post("/admin/targets/control-target/explode", ""))
+
+	// Beim Leeren werden keine neuen Jobs angenommen
+	control.State.Drain(current.Name)
+	assert.Equal(t, http.StatusServiceUnavailable, post("/jobs", `{"uid": "drained-uid", "data": "dmFsdWU="}`))
+
+	// Pausiert: Job wird geparkt, ohne das Target anzusprechen oder Versuche zu zählen
+	control.State.Pause(current.Name)
+	job := data.PendingJob{ID: "paused-job", Job: data.Job{UID: "paused-uid", Data: "dmFsdWU=", RunAt: time.Now()}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, 0, requests)
+	mu.Unlock()
+	pendingMutex.Lock()
+	assert.Equal(t, 0, pending[0].Attempts)
+	pendingMutex.Unlock()
+
+	control.State.Resume(current.Name)
+	<-done
+	assert.Empty(t, pending)
+}
@@ -3,11 +3,18 @@ package handlers
 import (
 	"net/http"
 
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/control"
 	"github.com/gin-gonic/gin"
 )
 
 func HealthHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+		response := gin.H{"message": "ok", "maintenance": control.State.Maintenance()}
+		if cfg := config.Active(); cfg != nil {
+			current := cfg.Current
+			response["targets"] = gin.H{current.Name: gin.H{"circuit": current.Breaker.State(), "state": control.State.Target(current.Name)}}
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
@@ -2,38 +2,267 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/control"
 	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/idempotency"
 	"djp.chapter42.de/a/internal/logger"
 	"djp.chapter42.de/a/internal/processor"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	ClientHeader      = "X-Client-ID"
+)
+
+// admission beschreibt das Ergebnis der Annahme eines einzelnen Jobs.
+type admission struct {
+	status     int
+	body       gin.H
+	replayed   bool
+	retryAfter time.Duration
+}
+
 func NewJobHandler(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var job data.Job
-		if err := c.BindJSON(&job); err != nil {
-			logger.Log.Warn("Fehler beim Parsen des JSON-Jobs:", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Ungültiges JSON-Format"})
+		job, body, err := readJob(c)
+		if err != nil {
+			logger.Log.Warn("Fehler beim Lesen des Jobs:", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateJob(job); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 			return
 		}
 
-		pending_job := data.PendingJob{Job: job, CreatedAt: time.Now()}
+		key := c.GetHeader(IdempotencyHeader)
+		hash := idempotency.Hash(body)
 
 		jobs_mutex.Lock()
-		*pending_jobs = append(*pending_jobs, pending_job)
+		result := admitJob(pending_jobs, job, clientID(c), key, hash, false)
 		jobs_mutex.Unlock()
 
-		select {
-		case processor.JobQueue <- pending_job:
-			logger.Log.Info("Neuer Job empfangen:", zap.String("uid", job.UID))
-			c.JSON(http.StatusAccepted, gin.H{"message": "Job akzeptiert", "uid": job.UID})
+		if result.replayed {
+			c.Header("Idempotent-Replayed", "true")
+		}
+		if result.retryAfter > 0 {
+			setRetryAfter(c, result.retryAfter)
+		}
+
+		c.JSON(result.status, result.body)
+	}
+}
+
+// admitJob wendet Idempotency-Key und Duplikat-Policy an, übergibt den Job an die
+// Queue und legt ihn erst danach in pending_jobs ab, sodass ein abgelehnter Job nie
+// gespeichert wird. Der Aufrufer muss jobs_mutex halten.
+func admitJob(pending_jobs *[]data.PendingJob, job data.Job, client, key, hash string, force bool) admission {
+	// Bereits bekannter Idempotency-Key: ursprüngliche Antwort wiederholen
+	if key != "" {
+		if entry, ok := idempotency.Keys.Lookup(key); ok {
+			if entry.Hash != hash {
+				return admission{status: http.StatusUnprocessableEntity, body: gin.H{"error": "Idempotency-Key wurde mit anderem Inhalt verwendet", "id": entry.JobID}}
+			}
+			return admission{status: http.StatusAccepted, body: gin.H{"message": "Job akzeptiert", "uid": entry.UID, "id": entry.JobID}, replayed: true}
+		}
+	}
+
+	// Beim Leeren werden keine neuen Jobs mehr angenommen
+	if !control.State.Accepting(targetName()) {
+		return admission{status: http.StatusServiceUnavailable, body: gin.H{"error": "Target wird geleert, es werden keine neuen Jobs angenommen"}}
+	}
+
+	pending_job := data.PendingJob{ID: data.NewJobID(), Job: job, CreatedAt: time.Now(), IdempotencyKey: key, Target: targetName(), Client: client}
+
+	// Gleiche UID bereits ausstehend: Policy des Targets anwenden
+	if i := lastPendingByUID(*pending_jobs, job.UID); i >= 0 {
+		existing := &(*pending_jobs)[i]
+
+		switch duplicatePolicy() {
+		case data.DuplicateReject:
+			logger.Log.Warn("Job mit gleicher UID ist bereits ausstehend:", zap.String("uid", job.UID))
+			return admission{status: http.StatusConflict, body: gin.H{"error": "Job mit dieser UID ist bereits ausstehend", "uid": job.UID, "id": existing.ID}}
+		case data.DuplicateReplace:
+			existing.Job = job
+			existing.Version++
+			rememberKey(key, hash, existing.ID, job.UID)
+
+			logger.Log.Info("Payload eines ausstehenden Jobs ersetzt:", zap.String("uid", job.UID), zap.String("id", existing.ID))
+			return admission{status: http.StatusAccepted, body: gin.H{"message": "Job akzeptiert", "uid": job.UID, "id": existing.ID, "replaced": "true"}}
 		default:
-			logger.Log.Error("Keine freien worker vorhanden für:", zap.String("uid", job.UID))
-			c.JSON(http.StatusServiceUnavailable, gin.H{"message": "Versuche es später nochmal", "uid": job.UID})
+			pending_job.After = existing.ID
+		}
+	}
+
+	if pending_job.After == "" {
+		if err := processor.Submit(pending_job, force); err != nil {
+			logger.Log.Error("Queue ist voll, Job abgelehnt:", zap.String("uid", job.UID))
+			return admission{status: http.StatusTooManyRequests, body: gin.H{"message": "Versuche es später nochmal", "uid": job.UID}, retryAfter: processor.RetryAfter()}
+		}
+	}
+
+	*pending_jobs = append(*pending_jobs, pending_job)
+	rememberKey(key, hash, pending_job.ID, job.UID)
+
+	// Eingereihte Jobs werden erst nach Abschluss ihres Vorgängers gestartet
+	if pending_job.After != "" {
+		logger.Log.Info("Job hinter ausstehendem Job mit gleicher UID eingereiht:", zap.String("uid", job.UID), zap.String("after", pending_job.After))
+		return admission{status: http.StatusAccepted, body: gin.H{"message": "Job akzeptiert", "uid": job.UID, "id": pending_job.ID, "after": pending_job.After}}
+	}
+
+	logger.Log.Info("Neuer Job empfangen:", zap.String("uid", job.UID))
+	body := gin.H{"message": "Job akzeptiert", "uid": job.UID, "id": pending_job.ID}
+	if due := job.DueAt(); due.After(pending_job.CreatedAt) {
+		body["due_at"] = due.Format(time.RFC3339)
+	}
+	return admission{status: http.StatusAccepted, body: body}
+}
+
+func lastPendingByUID(pending_jobs []data.PendingJob, uid string) int {
+	if uid == "" {
+		return -1
+	}
+	for i := len(pending_jobs) - 1; i >= 0; i-- {
+		if pending_jobs[i].Job.UID == uid {
+			return i
 		}
 	}
+	return -1
+}
+
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())))
+}
+
+func rememberKey(key, hash, jobID, uid string) {
+	if key == "" {
+		return
+	}
+	idempotency.Keys.Remember(idempotency.Entry{Key: key, Hash: hash, JobID: jobID, UID: uid, CreatedAt: time.Now()})
+}
+
+// clientID identifiziert den Einsender für die faire Verteilung der Worker.
+func clientID(c *gin.Context) string {
+	if id := c.GetHeader(ClientHeader); id != "" {
+		return id
+	}
+	return c.ClientIP()
+}
+
+func targetName() string {
+	cfg := config.Active()
+	if cfg == nil {
+		return ""
+	}
+	return cfg.Current.Name
+}
+
+func duplicatePolicy() string {
+	cfg := config.Active()
+	if cfg == nil || cfg.Current.DuplicatePolicy == "" {
+		return data.DuplicateQueue
+	}
+	return strings.ToLower(cfg.Current.DuplicatePolicy)
+}
+
+// GetJobHandler liefert den aktuellen Zustand eines ausstehenden Jobs.
+func GetJobHandler(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		jobs_mutex.Lock()
+		defer jobs_mutex.Unlock()
+
+		for _, j := range *pending_jobs {
+			if j.ID == id {
+				c.JSON(http.StatusOK, gin.H{"job": j, "rate_limit_wait": j.RateLimitWait.String()})
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job nicht gefunden", "id": id})
+	}
+}
+
+// ListJobsHandler liefert alle ausstehenden Jobs, die ältesten zuerst. Mit
+// ?uid= wird nach UID gefiltert.
+func ListJobsHandler(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := c.Query("uid")
+		now := time.Now()
+
+		jobs_mutex.Lock()
+		jobs := make([]gin.H, 0, len(*pending_jobs))
+		for _, j := range *pending_jobs {
+			if uid != "" && j.Job.UID != uid {
+				continue
+			}
+			jobs = append(jobs, gin.H{"job": j, "state": jobState(j, now)})
+		}
+		jobs_mutex.Unlock()
+
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs, "count": len(jobs)})
+	}
+}
+
+// CancelJobHandler bricht einen ausstehenden Job ab.
+func CancelJobHandler(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		jobs_mutex.Lock()
+		job, ok := processor.CancelJob(id, pending_jobs)
+		jobs_mutex.Unlock()
+
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job nicht gefunden", "id": id})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Job abgebrochen", "id": id, "uid": job.Job.UID})
+	}
+}
+
+// RequeueJobHandler setzt die Versuche eines Jobs zurück und versucht ihn sofort
+// erneut, sofern er gerade im Backoff wartet.
+func RequeueJobHandler(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		jobs_mutex.Lock()
+		defer jobs_mutex.Unlock()
+
+		for _, j := range *pending_jobs {
+			if j.ID == id && j.After != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": "Job wartet auf einen Vorgänger mit derselben UID", "id": id, "after": j.After})
+				return
+			}
+		}
+		job, active, ok := processor.RequeueJob(id, pending_jobs)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job nicht gefunden", "id": id})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Job erneut eingeplant", "id": id, "uid": job.Job.UID, "retrying": active})
+	}
+}
+
+// jobState fasst zusammen, woran ein Job gerade wartet.
+func jobState(j data.PendingJob, now time.Time) string {
+	switch {
+	case j.After != "":
+		return "waiting"
+	case j.Job.DueAt().After(now):
+		return "scheduled"
+	case j.Attempts > 0:
+		return "retrying"
+	default:
+		return "queued"
+	}
 }
@@ -0,0 +1,168 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/handlers"
+	"djp.chapter42.de/a/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// Liste der ausstehenden Jobs, die die Tests an die Handler übergeben.
+var (
+	pendingJobs []data.PendingJob
+	jobsMutex   sync.Mutex
+)
+
+func initTestLogger() *zap.Logger {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	logger, _ := cfg.Build()
+	return logger
+}
+
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	logger.Log = initTestLogger()
+	return router
+}
+
+func TestHandleNewJobIdempotency(t *testing.T) {
+	router := setupRouter()
+	router.POST("/jobs", handlers.NewJobHandler(&jobsMutex, &pendingJobs))
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/jobs", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(handlers.IdempotencyHeader, key)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	first := post("key-1", `{"uid": "idem-uid", "data": "dmFsdWU="}`)
+	assert.Equal(t, http.StatusAccepted, first.Code)
+	var firstResponse map[string]string
+	json.Unmarshal(first.Body.Bytes(), &firstResponse)
+
+	// Gleicher Key, gleicher Inhalt: ursprünglicher Job wird geliefert
+	replay := post("key-1", `{"uid": "idem-uid", "data": "dmFsdWU="}`)
+	assert.Equal(t, http.StatusAccepted, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	var replayResponse map[string]string
+	json.Unmarshal(replay.Body.Bytes(), &replayResponse)
+	assert.Equal(t, firstResponse["id"], replayResponse["id"])
+
+	// Gleicher Key, anderer Inhalt
+	conflict := post("key-1", `{"uid": "idem-uid", "data": "b3RoZXI="}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, conflict.Code)
+
+	// Neuer Key, gleiche UID: wird hinter dem ausstehenden Job eingereiht
+	queued := post("key-2", `{"uid": "idem-uid", "data": "b3RoZXI="}`)
+	assert.Equal(t, http.StatusAccepted, queued.Code)
+	var queuedResponse map[string]string
+	json.Unmarshal(queued.Body.Bytes(), &queuedResponse)
+	assert.Equal(t, firstResponse["id"], queuedResponse["after"])
+
+	jobsMutex.Lock()
+	assert.Len(t, pendingJobs, 2)
+	pendingJobs = []data.PendingJob{}
+	jobsMutex.Unlock()
+}
+
+func TestHandleNewJobPayloads(t *testing.T) {
+	router := setupRouter()
+	router.POST("/jobs", handlers.NewJobHandler(&jobsMutex, &pendingJobs))
+
+	post := func(contentType string, body io.Reader, query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/jobs"+query, body)
+		req.Header.Set("Content-Type", contentType)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	last := func() data.Job {
+		jobsMutex.Lock()
+		defer jobsMutex.Unlock()
+		return pendingJobs[len(pendingJobs)-1].Job
+	}
+	payloadOf := func(job data.Job) string {
+		payload, _ := base64.StdEncoding.DecodeString(job.Data)
+		return string(payload)
+	}
+
+	// Base64 mit und ohne Angabe der Kodierung
+	resp := post("application/json", strings.NewReader(`{"uid": "b64", "data": "dmFsdWU=", "encoding": "base64"}`), "")
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Equal(t, "value", payloadOf(last()))
+	assert.Empty(t, last().ContentType)
+
+	// JSON-Zeichenkette mit encoding json
+	resp = post("application/json", strings.NewReader(`{"uid": "text", "data": "kein base64", "encoding": "json"}`), "")
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Equal(t, `"kein base64"`, payloadOf(last()))
+	assert.Equal(t, "json", last().ContentType)
+
+	// Binär per application/octet-stream, Angaben als Query-Parameter
+	binary := []byte{0x82, 0xa1, 0x61, 0x01, 0x00, 0xff}
+	resp = post("application/octet-stream", bytes.NewReader(binary), "?uid=bin&content_type=msgpack&priority=high")
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Equal(t, string(binary), payloadOf(last()))
+	assert.Equal(t, "msgpack", last().ContentType)
+	assert.Equal(t, data.PriorityHigh, last().Priority)
+
+	// Datei per multipart/form-data
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("uid", "upload")
+	writer.WriteField("content_type", "xml")
+	writer.WriteField("run_at", "2030-01-02T03:04:05Z")
+	file, _ := writer.CreateFormFile("data", "order.xml")
+	file.Write([]byte("<order id=\"1\"/>"))
+	writer.Close()
+	resp = post(writer.FormDataContentType(), &form, "")
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Equal(t, `<order id="1"/>`, payloadOf(last()))
+	assert.Equal(t, "xml", last().ContentType)
+	assert.Equal(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), last().RunAt)
+
+	// Fehlerhafte Angaben
+	for _, body := range []string{
+		`{"uid": "bad", "data": "%%%", "encoding": "base64"}`,
+		`{"uid": "bad", "data": {"a": 1}, "encoding": "base64"}`,
+		`{"uid": "bad", "data": "dmFsdWU=", "encoding": "hex"}`,
+		`{"uid": "bad", "data": {"a": 1}, "content_type": "xml"}`,
+		`{"uid": "bad", "data": `,
+	} {
+		assert.Equal(t, http.StatusBadRequest, post("application/json", strings.NewReader(body), "").Code, body)
+	}
+	assert.Equal(t, http.StatusUnprocessableEntity, post("application/octet-stream", strings.NewReader("x"), "?uid=bad&content_type=pdf").Code)
+	// Gleiche Prüfung wie im Batch
+	for _, body := range []string{
+		`{"uid": "bad", "data": "dmFsdWU=", "priority": "urgent"}`,
+		`{"data": "dmFsdWU="}`,
+		`{"uid": "bad"}`,
+	} {
+		assert.Equal(t, http.StatusUnprocessableEntity, post("application/json", strings.NewReader(body), "").Code, body)
+	}
+	assert.Equal(t, http.StatusBadRequest, post("application/octet-stream", strings.NewReader("x"), "?uid=bad&run_at=morgen").Code)
+
+	jobsMutex.Lock()
+	assert.Len(t, pendingJobs, 4)
+	pendingJobs = []data.PendingJob{}
+	jobsMutex.Unlock()
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"github.com/gin-gonic/gin"
+)
+
+// Größte Datei, die bei multipart/form-data im Speicher gehalten wird.
+const maxMultipartMemory = 32 << 20
+
+// errInvalidJSON meldet einen Request, der kein gültiger JSON-Job ist.
+var errInvalidJSON = errors.New("Ungültiges JSON-Format")
+
+// readJob liest einen Job aus dem Request:
+//   - application/json (Standard): data als Base64 oder JSON, siehe data.Job
+//   - multipart/form-data: Datei oder Feld data, übrige Angaben als Felder
+//   - application/octet-stream: der Body ist der Payload, übrige Angaben als
+//     Query-Parameter (?uid=...&content_type=...)
+//
+// Neben dem Job liefert readJob den Inhalt, über den ein Idempotency-Key
+// verglichen wird.
+func readJob(c *gin.Context) (data.Job, []byte, error) {
+	var job data.Job
+	switch c.ContentType() {
+	case "multipart/form-data":
+		if err := c.Request.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return job, nil, err
+		}
+		payload, err := formPayload(c)
+		if err != nil {
+			return job, nil, err
+		}
+		return binaryJob(payload, c.PostForm)
+	case "application/octet-stream":
+		payload, err := c.GetRawData()
+		if err != nil {
+			return job, nil, err
+		}
+		return binaryJob(payload, c.Query)
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		return job, nil, err
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(body, &job); errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return job, nil, errInvalidJSON
+	} else if err != nil {
+		return job, nil, err
+	}
+	return job, body, nil
+}
+
+// formPayload liefert die Datei data oder, falls keine hochgeladen wurde, den
+// Wert des Feldes data.
+func formPayload(c *gin.Context) ([]byte, error) {
+	header, err := c.FormFile("data")
+	if errors.Is(err, http.ErrMissingFile) {
+		if value, ok := c.GetPostForm("data"); ok {
+			return []byte(value), nil
+		}
+		return nil, errors.New("data fehlt")
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// binaryJob baut einen Job aus einem Payload und den übrigen Angaben, die get
+// aus Formularfeldern bzw. Query-Parametern liest. Der Payload wird unverändert
+// übernommen.
+func binaryJob(payload []byte, get func(string) string) (data.Job, []byte, error) {
+	job := data.Job{
+		UID:         get("uid"),
+		Data:        base64.StdEncoding.EncodeToString(payload),
+		ContentType: get("content_type"),
+		Priority:    get("priority"),
+	}
+	for _, field := range []struct {
+		name string
+		t    *time.Time
+	}{{"run_at", &job.RunAt}, {"not_before", &job.NotBefore}} {
+		if value := get(field.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return job, nil, errors.New(field.name + " muss ein Zeitpunkt nach RFC 3339 sein")
+			}
+			*field.t = t
+		}
+	}
+
+	// Der Boundary eines Multipart-Requests ist zufällig, daher zählt der Job
+	content, err := json.Marshal(job)
+	return job, content, err
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/poller"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// pollerRequest entspricht data.PollerConfig, nimmt das Intervall aber als
+// lesbare Dauer (z.B. "30s") entgegen.
+type pollerRequest struct {
+	Name     string          `json:"name"`
+	Endpoint string          `json:"endpoint"`
+	UID      string          `json:"uid"`
+	Interval string          `json:"interval"`
+	Cron     string          `json:"cron"`
+	Spread   float64         `json:"spread"`
+	Detect   string          `json:"detect"`
+	Sink     data.SinkConfig `json:"sink"`
+}
+
+func ListPollersHandler(manager *poller.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"pollers": manager.List()})
+	}
+}
+
+func NewPollerHandler(manager *poller.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req pollerRequest
+		if err := c.BindJSON(&req); err != nil {
+			logger.Log.Warn("Fehler beim Parsen des Pollers:", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ungültiges JSON-Format"})
+			return
+		}
+
+		cfg := data.PollerConfig{
+			Name:     req.Name,
+			Endpoint: req.Endpoint,
+			UID:      req.UID,
+			Cron:     req.Cron,
+			Spread:   req.Spread,
+			Detect:   req.Detect,
+			Sink:     req.Sink,
+		}
+		if req.Interval != "" {
+			interval, err := time.ParseDuration(req.Interval)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Ungültiges Intervall: " + req.Interval})
+				return
+			}
+			cfg.Interval = interval
+		}
+
+		if err := manager.Start(cfg, true); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Poller gestartet", "name": cfg.Name})
+	}
+}
+
+func DeletePollerHandler(manager *poller.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if !manager.Stop(name) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poller nicht gefunden", "name": name})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Poller gestoppt", "name": name})
+	}
+}
+
+// PollerEventsHandler liefert Änderungsereignisse als Server-Sent Events.
+func PollerEventsHandler(manager *poller.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		events := manager.Events.Subscribe()
+		defer manager.Events.Unsubscribe(events)
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event := <-events:
+				c.SSEvent("change", event)
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
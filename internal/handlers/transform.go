@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"cmp"
+	"net/http"
+	"time"
+
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/processor"
+	"github.com/gin-gonic/gin"
+)
+
+// TransformHandler zeigt, welchen Payload ein Target für einen Job erhalten
+// würde, ohne den Job anzunehmen oder das Target aufzurufen. Der Job wird wie
+// bei POST /jobs übergeben, die Revision optional per ?revision=.
+func TransformHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Active()
+		if cfg == nil || c.Param("name") != cfg.Current.Name {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target nicht gefunden"})
+			return
+		}
+		current := &cfg.Current
+
+		job, _, err := readJob(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkContentType(job.ContentType); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		pending := data.PendingJob{Job: job, CreatedAt: time.Now(), Target: current.Name, Client: clientID(c)}
+		payload, err := processor.Transform(pending, c.Query("revision"), current)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		contentType := cmp.Or(convert.MIME(cmp.Or(current.ContentType, job.ContentType)), "application/octet-stream")
+		c.Data(http.StatusOK, contentType, payload)
+	}
+}
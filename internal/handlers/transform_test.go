@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/handlers"
+	"djp.chapter42.de/a/internal/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransform(t *testing.T) {
+	router := setupRouter()
+	router.POST("/targets/:name/transform", handlers.TransformHandler())
+
+	var written string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "r8"}`))
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			written = string(body)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "wavely.cfg.yaml")
+	content := `
+current:
+  name: "transform-target"
+  base_url: "` + server.URL + `"
+  endpoints:
+    check: "/objects/{{.UID}}"
+    revision: "/revision/{{.UID}}"
+    write: "/objects/{{.UID}}"
+  content_type: "json"
+  convert:
+    xml:
+      root: "meta"
+  transform: >-
+    {"id": {{json .UID}}, "revision": {{json .Revision}}, "target": {{json .Meta.Target}},
+    "title": {{get .Value "order.title" | trim | upper | json}},
+    "skus": "{{range $i, $l := .Value.order.lines}}{{if $i}}|{{end}}{{$l.sku}}{{end}}",
+    "total": {{add (get .Value "order.lines.0.qty") (get .Value "order.lines.1.qty")}},
+    "note": {{get .Value "order.note" | default "keine" | json}},
+    "meta": {{encode "xml" .Value.order.meta | json}}}
+  auth:
+    type: "none"
+  max_workers: 1
+`
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	config.ConfigFile = file
+	defer func() { config.ConfigFile = "" }()
+	if !assert.NoError(t, config.InitConfig()) {
+		return
+	}
+
+	job := `{"uid": "order-1", "data": {"order": {"title": " Bestellung ", "lines": [{"sku": "a1", "qty": 2}, {"sku": "b2", "qty": 3}], "meta": {"@v": "1", "by": "ops"}}}}`
+	preview := func(target, query, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/targets/"+target+"/transform"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Vorschau mit Revision aus der Query
+	resp := preview("transform-target", "?revision=r7", job)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	expected := `{"id": "order-1", "revision": "r7", "target": "transform-target", "title": "BESTELLUNG", "skus": "a1|b2", "total": 5, "note": "keine", ` +
+		`"meta": "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<meta v=\"1\"><by>ops</by></meta>"}`
+	assert.Equal(t, expected, resp.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, preview("other-target", "", job).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, preview("transform-target", "", `{"uid": "x", "data": {"order": {}}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, preview("transform-target", "", `{"uid": "x", "data": `).Code)
+
+	// Beim Schreiben erhält das Template die ermittelte Revision, die UID bleibt
+	current := &config.Active().Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+	var parsed data.Job
+	assert.NoError(t, json.Unmarshal([]byte(job), &parsed))
+	pendingJob := data.PendingJob{ID: "transform-job", Job: parsed}
+	pending := []data.PendingJob{pendingJob}
+	var pendingMutex sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(pendingJob, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	wait, _ := fake.NextWait()
+	fake.Advance(wait)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, strings.ReplaceAll(expected, `"r7"`, `"r8"`), written)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const DefaultWindow = 24 * time.Hour
+
+// Entry merkt sich, welcher Job zu einem Idempotency-Key angelegt wurde.
+type Entry struct {
+	Key       string    `json:"key"`
+	Hash      string    `json:"hash"`
+	JobID     string    `json:"job_id"`
+	UID       string    `json:"uid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store hält die Idempotency-Keys für das konfigurierte Zeitfenster.
+type Store struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]Entry
+}
+
+// Keys ist der globale Store, den der Job-Handler verwendet.
+var Keys = NewStore(DefaultWindow)
+
+func NewStore(window time.Duration) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Store{window: window, entries: make(map[string]Entry)}
+}
+
+func (s *Store) SetWindow(window time.Duration) {
+	if window <= 0 {
+		return
+	}
+	s.mu.Lock()
+	s.window = window
+	s.mu.Unlock()
+}
+
+// Lookup liefert den Eintrag zu einem Key, solange er noch im Zeitfenster liegt.
+func (s *Store) Lookup(key string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return Entry{}, false
+	}
+	if time.Since(entry.CreatedAt) > s.window {
+		delete(s.entries, key)
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+func (s *Store) Remember(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[entry.Key] = entry
+	s.prune()
+}
+
+// Snapshot liefert alle noch gültigen Einträge, z.B. für die Persistierung.
+func (s *Store) Snapshot() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *Store) Load(entries []Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		s.entries[entry.Key] = entry
+	}
+	s.prune()
+}
+
+func (s *Store) prune() {
+	for key, entry := range s.entries {
+		if time.Since(entry.CreatedAt) > s.window {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Hash bildet den Fingerabdruck eines Request-Bodys, um wiederverwendete Keys
+// mit abweichendem Inhalt zu erkennen.
+func Hash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -9,6 +10,10 @@ import (
 
 var Log *zap.Logger
 
+// Dir überschreibt das Verzeichnis der Logdatei (Standard: ./log im Debug-Modus,
+// sonst /app/logs).
+var Dir string
+
 func InitLogger(debug bool) {
 	var logEncoding string
 	var logFilePath string
@@ -23,6 +28,9 @@ func InitLogger(debug bool) {
 		logEncoding = "json"
 		logFilePath = "/app/logs/wavely.log"
 	}
+	if Dir != "" {
+		logFilePath = filepath.Join(Dir, "wavely.log")
+	}
 
 	cfg := zap.Config{
 		Level:            level,
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Einfache Metriken im Prometheus-Textformat, ohne zusätzliche Abhängigkeiten.
+
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+type family struct {
+	kind   string
+	help   string
+	values map[string]float64 // gerenderte Labels -> Wert
+}
+
+var (
+	mu       sync.Mutex
+	families = make(map[string]*family)
+)
+
+// Describe hinterlegt einen Hilfetext für eine Metrik.
+func Describe(name, help string) {
+	mu.Lock()
+	defer mu.Unlock()
+	get(name, "").help = help
+}
+
+// Add erhöht einen Zähler. labels sind abwechselnd Name und Wert.
+func Add(name string, value float64, labels ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	get(name, typeCounter).values[renderLabels(labels)] += value
+}
+
+// Set setzt einen Messwert. labels sind abwechselnd Name und Wert.
+func Set(name string, value float64, labels ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	get(name, typeGauge).values[renderLabels(labels)] = value
+}
+
+// Value liefert den aktuellen Wert einer Metrik, z.B. für Statusausgaben.
+func Value(name string, labels ...string) float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	if f, ok := families[name]; ok {
+		return f.values[renderLabels(labels)]
+	}
+	return 0
+}
+
+func get(name, kind string) *family {
+	f, ok := families[name]
+	if !ok {
+		f = &family{values: make(map[string]float64)}
+		families[name] = f
+	}
+	if f.kind == "" {
+		f.kind = kind
+	}
+	return f
+}
+
+func renderLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Handler liefert alle Metriken im Prometheus-Textformat.
+func Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mu.Lock()
+		names := make([]string, 0, len(families))
+		for name := range families {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var b strings.Builder
+		for _, name := range names {
+			f := families[name]
+			if len(f.values) == 0 {
+				continue
+			}
+			if f.help != "" {
+				fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+			}
+			fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+
+			keys := make([]string, 0, len(f.values))
+			for labels := range f.values {
+				keys = append(keys, labels)
+			}
+			sort.Strings(keys)
+			for _, labels := range keys {
+				fmt.Fprintf(&b, "%s%s %g\n", name, labels, f.values[labels])
+			}
+		}
+		mu.Unlock()
+
+		c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	}
+}
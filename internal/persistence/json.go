@@ -3,16 +3,38 @@ package persistence
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"sync"
 
+	"djp.chapter42.de/a/internal/control"
 	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/idempotency"
 	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/poller"
 	"djp.chapter42.de/a/internal/processor"
 	"go.uber.org/zap"
 )
 
-// const PersistenceFileName string = "./cache/pending_jobs.json"
-const PersistenceFileName string = "/app/cache/pending_jobs.json"
+const DefaultCacheDir string = "/app/cache"
+
+// Dateien im Cache-Verzeichnis, siehe SetCacheDir
+var (
+	PersistenceFileName = filepath.Join(DefaultCacheDir, "pending_jobs.json")
+	IdempotencyFileName = filepath.Join(DefaultCacheDir, "idempotency_keys.json")
+	PollerFileName      = filepath.Join(DefaultCacheDir, "pollers.json")
+	ControlFileName     = filepath.Join(DefaultCacheDir, "controls.json")
+	LeaseFileName       = filepath.Join(DefaultCacheDir, "leases.json")
+)
+
+// SetCacheDir legt das Verzeichnis fest, in dem Jobs, Idempotency-Keys, Poller,
+// Betriebszustand und gehaltene Sperren persistiert werden.
+func SetCacheDir(dir string) {
+	PersistenceFileName = filepath.Join(dir, "pending_jobs.json")
+	IdempotencyFileName = filepath.Join(dir, "idempotency_keys.json")
+	PollerFileName = filepath.Join(dir, "pollers.json")
+	ControlFileName = filepath.Join(dir, "controls.json")
+	LeaseFileName = filepath.Join(dir, "leases.json")
+}
 
 func SavePendingJobs(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) {
 	jobs_mutex.Lock()
@@ -20,41 +42,191 @@ func SavePendingJobs(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob) {
 
 	if pending_jobs == nil || len(*pending_jobs) == 0 {
 		logger.Log.Info("Es stehen keine ausstehenden Jobs an.")
+		// Eine Datei vom letzten Lauf würde bereits geschriebene Jobs wiederherstellen
+		if err := os.Remove(PersistenceFileName); err != nil && !os.IsNotExist(err) {
+			logger.Log.Error("Fehler beim Entfernen der Datei mit ausstehenden Jobs:", zap.String("filename", PersistenceFileName), zap.Error(err))
+		}
+		return
+	}
+
+	if err := WritePendingJobs(*pending_jobs); err != nil {
+		logger.Log.Error("Fehler beim Speichern der ausstehenden Jobs in die Datei:", zap.String("filename", PersistenceFileName), zap.Error(err))
+	} else {
+		logger.Log.Info("Ausstehende Jobs in Datei gespeichert:", zap.String("filename", PersistenceFileName), zap.Int("count", len(*pending_jobs)))
+	}
+}
+
+func RestorePendingJobs(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob, currentCfg *data.CurrentConfig) {
+	restored, err := ReadPendingJobs()
+	if err != nil {
+		logger.Log.Error("Fehler beim Lesen der ausstehenden Jobs aus der Datei:", zap.String("filename", PersistenceFileName), zap.Error(err))
+		return
+	}
+	if len(restored) == 0 {
 		return
 	}
 
-	data, err := json.MarshalIndent(*pending_jobs, "", "  ")
+	logger.Log.Info("Ausstehende Jobs aus Datei wiederhergestellt:", zap.String("filename", PersistenceFileName), zap.Int("count", len(restored)))
+
+	pending := make(map[string]bool, len(restored))
+	for i, job := range restored {
+		// Jobs aus älteren Dateien haben noch keine ID
+		if job.ID == "" {
+			restored[i].ID = data.NewJobID()
+		}
+		pending[restored[i].ID] = true
+	}
+
+	var runnable []data.PendingJob
+	for i, job := range restored {
+		// Eingereihte Jobs warten weiterhin auf ihren Vorgänger
+		if job.After != "" && pending[job.After] {
+			continue
+		}
+		restored[i].After = ""
+		runnable = append(runnable, restored[i])
+	}
+
+	jobs_mutex.Lock()
+	*pending_jobs = append(*pending_jobs, restored...)
+	jobs_mutex.Unlock()
+
+	// Über die Queue einplanen, damit das Worker-Limit auch beim Start gilt
+	processor.ScheduleRestored(runnable, currentCfg.Restore.Rate)
+}
+
+// ReadPendingJobs liest die persistierten Jobs. Fehlt die Datei, stehen keine
+// Jobs aus.
+func ReadPendingJobs() ([]data.PendingJob, error) {
+	content, err := os.ReadFile(PersistenceFileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []data.PendingJob
+	if err := json.Unmarshal(content, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func WritePendingJobs(jobs []data.PendingJob) error {
+	data, err := json.MarshalIndent(jobs, "", "  ")
 	if err != nil {
-		logger.Log.Error("Fehler beim Serialisieren der ausstehenden Jobs:", zap.Error(err))
+		return err
+	}
+	return os.WriteFile(PersistenceFileName, data, 0644)
+}
+
+func SaveIdempotencyKeys(store *idempotency.Store) {
+	entries := store.Snapshot()
+	if len(entries) == 0 {
 		return
 	}
 
-	err = os.WriteFile(PersistenceFileName, data, 0644)
+	data, err := json.MarshalIndent(entries, "", "  ")
 	if err != nil {
-		logger.Log.Error("Fehler beim Speichern der ausstehenden Jobs in die Datei:", zap.String("filename", PersistenceFileName), zap.Error(err))
+		logger.Log.Error("Fehler beim Serialisieren der Idempotency-Keys:", zap.Error(err))
+		return
+	}
+
+	err = os.WriteFile(IdempotencyFileName, data, 0644)
+	if err != nil {
+		logger.Log.Error("Fehler beim Speichern der Idempotency-Keys in die Datei:", zap.String("filename", IdempotencyFileName), zap.Error(err))
 	} else {
-		logger.Log.Info("Ausstehende Jobs in Datei gespeichert:", zap.String("filename", PersistenceFileName), zap.Int("count", len(*pending_jobs)))
+		logger.Log.Info("Idempotency-Keys in Datei gespeichert:", zap.String("filename", IdempotencyFileName), zap.Int("count", len(entries)))
 	}
 }
 
-func RestorePendingJobs(jobs_mutex *sync.Mutex, pending_jobs *[]data.PendingJob, currentCfg *data.CurrentConfig) {
-	data, err := os.ReadFile(PersistenceFileName)
+func RestoreIdempotencyKeys(store *idempotency.Store) {
+	data, err := os.ReadFile(IdempotencyFileName)
 	if err != nil {
 		if !os.IsNotExist(err) {
-			logger.Log.Error("Fehler beim Lesen der ausstehenden Jobs aus der Datei:", zap.String("filename", PersistenceFileName), zap.Error(err))
+			logger.Log.Error("Fehler beim Lesen der Idempotency-Keys aus der Datei:", zap.String("filename", IdempotencyFileName), zap.Error(err))
+		}
+		return
+	}
+
+	var entries []idempotency.Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logger.Log.Error("Fehler beim Deserialisieren der Idempotency-Keys:", zap.String("filename", IdempotencyFileName), zap.Error(err))
+		return
+	}
+	store.Load(entries)
+}
+
+func SavePollers(manager *poller.Manager) {
+	snapshots := manager.Snapshot()
+	if len(snapshots) == 0 {
+		// Eine Datei vom letzten Lauf würde gelöschte Poller wiederherstellen
+		if err := os.Remove(PollerFileName); err != nil && !os.IsNotExist(err) {
+			logger.Log.Error("Fehler beim Entfernen der Datei mit Pollern:", zap.String("filename", PollerFileName), zap.Error(err))
 		}
 		return
 	}
 
-	err = json.Unmarshal(data, &pending_jobs)
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		logger.Log.Error("Fehler beim Serialisieren der Poller:", zap.Error(err))
+		return
+	}
+
+	err = os.WriteFile(PollerFileName, data, 0644)
+	if err != nil {
+		logger.Log.Error("Fehler beim Speichern der Poller in die Datei:", zap.String("filename", PollerFileName), zap.Error(err))
+	} else {
+		logger.Log.Info("Poller in Datei gespeichert:", zap.String("filename", PollerFileName), zap.Int("count", len(snapshots)))
+	}
+}
+
+func RestorePollers(manager *poller.Manager) {
+	data, err := os.ReadFile(PollerFileName)
 	if err != nil {
-		logger.Log.Error("Fehler beim Deserialisieren der ausstehenden Jobs:", zap.String("filename", PersistenceFileName), zap.Error(err))
+		if !os.IsNotExist(err) {
+			logger.Log.Error("Fehler beim Lesen der Poller aus der Datei:", zap.String("filename", PollerFileName), zap.Error(err))
+		}
 		return
 	}
 
-	logger.Log.Info("Ausstehende Jobs aus Datei wiederhergestellt:", zap.String("filename", PersistenceFileName), zap.Int("count", len(*pending_jobs)))
+	var snapshots []poller.Snapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		logger.Log.Error("Fehler beim Deserialisieren der Poller:", zap.String("filename", PollerFileName), zap.Error(err))
+		return
+	}
+	manager.Load(snapshots)
+}
+
+// SaveControls wird nach jeder Änderung aufgerufen, damit pausierte Targets auch
+// einen Absturz überstehen.
+func SaveControls(controls *control.Controls) error {
+	data, err := json.MarshalIndent(controls.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(ControlFileName, data, 0644); err != nil {
+		logger.Log.Error("Fehler beim Speichern des Betriebszustands in die Datei:", zap.String("filename", ControlFileName), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func RestoreControls(controls *control.Controls) {
+	data, err := os.ReadFile(ControlFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Log.Error("Fehler beim Lesen des Betriebszustands aus der Datei:", zap.String("filename", ControlFileName), zap.Error(err))
+		}
+		return
+	}
 
-	for _, job := range *pending_jobs {
-		go processor.ProcessJob(job, pending_jobs, jobs_mutex, currentCfg)
+	var snapshot control.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		logger.Log.Error("Fehler beim Deserialisieren des Betriebszustands:", zap.String("filename", ControlFileName), zap.Error(err))
+		return
 	}
+	controls.Load(snapshot)
+	logger.Log.Info("Betriebszustand wiederhergestellt:", zap.Bool("maintenance", snapshot.Maintenance), zap.Any("targets", snapshot.Targets))
 }
@@ -0,0 +1,42 @@
+package persistence_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/persistence"
+	"djp.chapter42.de/a/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSavePollersRemovesStaleFile(t *testing.T) {
+	logger.Log = zap.NewNop()
+	persistence.SetCacheDir(t.TempDir())
+	defer persistence.SetCacheDir(persistence.DefaultCacheDir)
+
+	current := &data.CurrentConfig{Name: "persistence-target"}
+	config.SetClock(current, clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC)))
+	m := poller.NewManager(current)
+	defer m.StopAll()
+
+	assert.NoError(t, m.Start(data.PollerConfig{Name: "api", Endpoint: "/a", Cron: "@yearly"}, true))
+	persistence.SavePollers(m)
+	assert.FileExists(t, persistence.PollerFileName)
+
+	// Nach dem Löschen des letzten Pollers darf er beim nächsten Start nicht zurückkehren
+	assert.True(t, m.Stop("api"))
+	persistence.SavePollers(m)
+	_, err := os.Stat(persistence.PollerFileName)
+	assert.True(t, os.IsNotExist(err))
+
+	restored := poller.NewManager(current)
+	defer restored.StopAll()
+	persistence.RestorePollers(restored)
+	assert.Empty(t, restored.List())
+}
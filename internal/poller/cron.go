@@ -0,0 +1,156 @@
+package poller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule ist ein geparster Cron-Ausdruck mit fünf Feldern
+// (Minute, Stunde, Tag, Monat, Wochentag).
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// Maximale Suchweite für den nächsten Zeitpunkt (Schaltjahre eingeschlossen)
+const cronSearchLimit = 5 * 366 * 24 * 60
+
+// Längste Länge der Monate; den 29. Februar gibt es in Schaltjahren
+var monthDays = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+func ParseCron(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[expr]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron-Ausdruck %q benötigt 5 Felder", expr)
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("stunde: %w", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("tag: %w", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("monat: %w", err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("wochentag: %w", err)
+	}
+	// Sonntag darf als 0 oder 7 angegeben werden
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	if !s.possible() {
+		return nil, fmt.Errorf("cron-Ausdruck %q trifft nie zu", expr)
+	}
+	return s, nil
+}
+
+// possible meldet, ob einer der Tage in einem der Monate vorkommt (z.B. nicht
+// beim 30. Februar). Mit eingeschränktem Wochentag genügt bereits dieser.
+func (s *Schedule) possible() bool {
+	if !s.dowStar && !s.domStar {
+		return true
+	}
+	for month := 1; month <= 12; month++ {
+		days := uint64(1)<<uint(monthDays[month]+1) - 2
+		if s.month&(1<<uint(month)) != 0 && s.dom&days != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("ungültige Schrittweite in %q", part)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("ungültiger Wert %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("ungültiger Bereich %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("wert %q außerhalb von %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next liefert den ersten passenden Zeitpunkt nach t (minutengenau). Nicht
+// passende Tage werden am Stück übersprungen.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < cronSearchLimit; i++ {
+		if !s.matchesDay(t) {
+			year, month, day := t.Date()
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) != 0 && s.hour&(1<<uint(t.Hour())) != 0 {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	if s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	// Wie bei cron: sind Tag und Wochentag eingeschränkt, genügt einer von beiden
+	if !s.domStar && !s.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
@@ -0,0 +1,42 @@
+package poller_test
+
+import (
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/poller"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronSchedule(t *testing.T) {
+	schedule, err := poller.ParseCron("*/15 8-17 * * 1-5")
+	assert.NoError(t, err)
+
+	// Freitag 17:50 -> Montag 08:00
+	from := time.Date(2025, 5, 16, 17, 50, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC), schedule.Next(from))
+
+	next := schedule.Next(time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 5, 19, 8, 15, 0, 0, time.UTC), next)
+
+	_, err = poller.ParseCron("* * *")
+	assert.Error(t, err)
+	_, err = poller.ParseCron("61 * * * *")
+	assert.Error(t, err)
+
+	// Ausdrücke, die nie zutreffen, werden beim Parsen abgelehnt
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *", "0 0 30-31 2 *"} {
+		_, err = poller.ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+
+	// Der 29. Februar kommt nur in Schaltjahren vor
+	schedule, err = poller.ParseCron("0 0 29 2 *")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), schedule.Next(from))
+
+	// Mit eingeschränktem Wochentag genügt einer von beiden: jeder Montag im Februar
+	schedule, err = poller.ParseCron("0 0 30 2 1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC), schedule.Next(from))
+}
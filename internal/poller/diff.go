@@ -0,0 +1,63 @@
+package poller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// diffJSON liefert die Pfade, die sich zwischen zwei JSON-Dokumenten geändert
+// haben. Ist eines der Dokumente kein JSON, wird nil geliefert.
+func diffJSON(previous, current []byte) []string {
+	var a, b interface{}
+	if json.Unmarshal(previous, &a) != nil || json.Unmarshal(current, &b) != nil {
+		return nil
+	}
+
+	var changes []string
+	diffValue("$", a, b, &changes)
+	sort.Strings(changes)
+	return changes
+}
+
+func diffValue(path string, a, b interface{}, changes *[]string) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			*changes = append(*changes, path)
+			return
+		}
+		for key, value := range av {
+			if other, ok := bv[key]; ok {
+				diffValue(path+"."+key, value, other, changes)
+			} else {
+				*changes = append(*changes, path+"."+key)
+			}
+		}
+		for key := range bv {
+			if _, ok := av[key]; !ok {
+				*changes = append(*changes, path+"."+key)
+			}
+		}
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			*changes = append(*changes, path)
+			return
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= len(av) || i >= len(bv) {
+				*changes = append(*changes, itemPath)
+				continue
+			}
+			diffValue(itemPath, av[i], bv[i], changes)
+		}
+	default:
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, path)
+		}
+	}
+}
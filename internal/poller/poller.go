@@ -0,0 +1,373 @@
+package poller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/control"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/external"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/tmpl"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultSpread = 0.1
+	// Schritte innerhalb einer Sinuswelle, analog zum Sinus-Backoff
+	spreadOscillation = 10
+	// Goldener Schnitt: verteilt die Phasen nacheinander gestarteter Poller gleichmäßig
+	goldenRatio = 0.6180339887498949
+)
+
+// Pollers ist der globale Manager der wiederkehrenden Abfragen.
+var Pollers *Manager
+
+// Status ist der öffentlich sichtbare Zustand eines Pollers.
+type Status struct {
+	Config     data.PollerConfig `json:"config"`
+	Dynamic    bool              `json:"dynamic"`
+	LastPoll   time.Time         `json:"last_poll,omitzero"`
+	LastChange time.Time         `json:"last_change,omitzero"`
+	LastError  string            `json:"last_error,omitempty"`
+	Hash       string            `json:"hash,omitempty"`
+}
+
+// Snapshot enthält alles, was ein Poller über einen Neustart hinweg behalten muss.
+type Snapshot struct {
+	Config   data.PollerConfig `json:"config"`
+	Dynamic  bool              `json:"dynamic"`
+	LastHash string            `json:"last_hash,omitempty"`
+	LastBody []byte            `json:"last_body,omitempty"`
+}
+
+type Manager struct {
+	mu      sync.Mutex
+	cfg     *data.CurrentConfig
+	pollers map[string]*poller
+	started int
+	Events  *Broker
+}
+
+type poller struct {
+	mu       sync.Mutex
+	cfg      data.PollerConfig
+	dynamic  bool
+	tpl      *template.Template
+	schedule *Schedule
+	sink     Sink
+	phase    float64
+	clock    clock.Clock
+	stop     chan struct{}
+
+	lastPoll   time.Time
+	lastChange time.Time
+	lastError  string
+	lastHash   string
+	lastBody   []byte
+}
+
+func NewManager(cfg *data.CurrentConfig) *Manager {
+	return &Manager{cfg: cfg, pollers: make(map[string]*poller), Events: NewBroker()}
+}
+
+// Start prüft die Konfiguration und startet den Poller. dynamic kennzeichnet
+// Poller, die über die API angelegt wurden und daher persistiert werden.
+func (m *Manager) Start(cfg data.PollerConfig, dynamic bool) error {
+	p, err := m.build(cfg, dynamic)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.pollers[cfg.Name]; exists {
+		return fmt.Errorf("poller %q existiert bereits", cfg.Name)
+	}
+
+	p.phase = 2 * math.Pi * math.Mod(float64(m.started)*goldenRatio, 1)
+	p.clock = clock.Or(m.cfg.Clock)
+	m.started++
+	m.pollers[cfg.Name] = p
+	go p.run(m)
+
+	logger.Log.Info("Poller gestartet:", zap.String("name", cfg.Name), zap.String("endpoint", cfg.Endpoint))
+	return nil
+}
+
+func (m *Manager) build(cfg data.PollerConfig, dynamic bool) (*poller, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("poller benötigt einen name")
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("poller benötigt einen endpoint")
+	}
+	if (cfg.Interval <= 0) == (cfg.Cron == "") {
+		return nil, errors.New("poller benötigt entweder interval oder cron")
+	}
+	switch cfg.Detect {
+	case "":
+		cfg.Detect = data.DetectHash
+	case data.DetectHash, data.DetectDiff:
+	default:
+		return nil, fmt.Errorf("unbekannte Änderungserkennung: %s", cfg.Detect)
+	}
+	if cfg.Spread <= 0 {
+		cfg.Spread = DefaultSpread
+	}
+
+	p := &poller{cfg: cfg, dynamic: dynamic, stop: make(chan struct{})}
+
+	var err error
+	if p.tpl, err = template.New(cfg.Name).Parse(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("error in poller endpoint template [%s]: %w", cfg.Name, err)
+	}
+	if cfg.Cron != "" {
+		if p.schedule, err = ParseCron(cfg.Cron); err != nil {
+			return nil, err
+		}
+	}
+	if p.sink, err = buildSink(cfg.Sink, m.Events); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload übernimmt eine neu geladene Konfiguration. Nur geänderte Poller aus der
+// Konfiguration werden neu gestartet; bleiben Endpoint und UID gleich, behalten
+// sie ihren letzten Stand. Über die API angelegte Poller bleiben unberührt.
+func (m *Manager) Reload(cfg *data.CurrentConfig) {
+	m.mu.Lock()
+	m.cfg = cfg
+	previous := make(map[string]*poller)
+	for name, p := range m.pollers {
+		if !p.dynamic {
+			previous[name] = p
+		}
+	}
+	m.mu.Unlock()
+
+	for _, pc := range cfg.Pollers {
+		old, existed := previous[pc.Name]
+		delete(previous, pc.Name)
+		if existed {
+			if next, err := m.build(pc, false); err == nil && reflect.DeepEqual(next.cfg, old.cfg) {
+				continue
+			}
+			m.Stop(pc.Name)
+		}
+
+		if err := m.Start(pc, false); err != nil {
+			logger.Log.Error("Poller konnte nicht gestartet werden:", zap.String("name", pc.Name), zap.Error(err))
+			continue
+		}
+		if existed && old.cfg.Endpoint == pc.Endpoint && old.cfg.UID == pc.UID {
+			old.mu.Lock()
+			m.Load([]Snapshot{{Config: pc, LastHash: old.lastHash, LastBody: old.lastBody}})
+			old.mu.Unlock()
+		}
+	}
+
+	for name := range previous {
+		m.Stop(name)
+	}
+}
+
+func (m *Manager) current() *data.CurrentConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cfg
+}
+
+func (m *Manager) Stop(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pollers[name]
+	if !ok {
+		return false
+	}
+	close(p.stop)
+	delete(m.pollers, name)
+	logger.Log.Info("Poller gestoppt:", zap.String("name", name))
+	return true
+}
+
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, p := range m.pollers {
+		close(p.stop)
+		delete(m.pollers, name)
+	}
+}
+
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Status, 0, len(m.pollers))
+	for _, p := range m.pollers {
+		p.mu.Lock()
+		list = append(list, Status{
+			Config:     p.cfg,
+			Dynamic:    p.dynamic,
+			LastPoll:   p.lastPoll,
+			LastChange: p.lastChange,
+			LastError:  p.lastError,
+			Hash:       p.lastHash,
+		})
+		p.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Config.Name < list[j].Config.Name })
+	return list
+}
+
+func (m *Manager) Snapshot() []Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshots := make([]Snapshot, 0, len(m.pollers))
+	for _, p := range m.pollers {
+		p.mu.Lock()
+		snapshots = append(snapshots, Snapshot{Config: p.cfg, Dynamic: p.dynamic, LastHash: p.lastHash, LastBody: p.lastBody})
+		p.mu.Unlock()
+	}
+	return snapshots
+}
+
+// Load startet persistierte API-Poller erneut und übernimmt den letzten bekannten
+// Stand, damit Änderungen während der Downtime erkannt werden.
+func (m *Manager) Load(snapshots []Snapshot) {
+	for _, s := range snapshots {
+		if s.Dynamic {
+			if err := m.Start(s.Config, true); err != nil {
+				logger.Log.Warn("Persistierter Poller konnte nicht gestartet werden:", zap.String("name", s.Config.Name), zap.Error(err))
+				continue
+			}
+		}
+
+		m.mu.Lock()
+		p, ok := m.pollers[s.Config.Name]
+		m.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		p.mu.Lock()
+		if p.lastHash == "" {
+			p.lastHash = s.LastHash
+			p.lastBody = s.LastBody
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *poller) run(m *Manager) {
+	for step := 0; ; step++ {
+		select {
+		case <-p.clock.After(p.nextDelay(step, p.clock.Now())):
+			p.poll(m.current())
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// nextDelay berechnet die Wartezeit bis zur nächsten Abfrage. Die Abfragen werden
+// sinusförmig um den Takt gestreut; die Phase unterscheidet sich je Poller, damit
+// gleichzeitig konfigurierte Poller das Target nicht im Gleichschritt treffen.
+func (p *poller) nextDelay(step int, now time.Time) time.Duration {
+	wave := math.Sin(float64(step)*(math.Pi/spreadOscillation) + p.phase)
+
+	if p.schedule != nil {
+		next := p.schedule.Next(now)
+		if next.IsZero() {
+			return 24 * time.Hour
+		}
+		period := p.schedule.Next(next).Sub(next)
+		// Nie vor dem Cron-Zeitpunkt, sondern höchstens spread*Periode danach
+		offset := time.Duration((wave + 1) / 2 * p.cfg.Spread * float64(period))
+		return next.Sub(now) + offset
+	}
+
+	interval := p.cfg.Interval
+	if step == 0 {
+		// Erster Start: über das Intervall verteilt
+		return time.Duration(p.phase / (2 * math.Pi) * float64(interval))
+	}
+	return interval + time.Duration(wave*p.cfg.Spread*float64(interval))
+}
+
+func (p *poller) poll(currentCfg *data.CurrentConfig) {
+	// Pausierte Targets und Wartungsmodus: Abfrage auslassen
+	if !control.State.Allowed(currentCfg.Name) {
+		logger.Log.Debug("Target angehalten, Abfrage ausgelassen:", zap.String("poller", p.cfg.Name))
+		return
+	}
+
+	// Bei offenem Circuit Breaker wird die Abfrage ausgelassen
+	if ok, _ := currentCfg.Breaker.Allow(); !ok {
+		logger.Log.Debug("Circuit Breaker offen, Abfrage ausgelassen:", zap.String("poller", p.cfg.Name))
+		return
+	}
+
+	endpoint, err := tmpl.RenderEndpoint(p.tpl, data.Job{UID: p.cfg.UID})
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	url := currentCfg.BaseURL + endpoint
+
+	body, status, err := external.Fetch(url, currentCfg)
+	if err != nil {
+		p.fail(err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	p.mu.Lock()
+	p.lastPoll = p.clock.Now()
+	p.lastError = ""
+	previousHash, previousBody := p.lastHash, p.lastBody
+	p.lastHash, p.lastBody = hash, body
+	if previousHash == "" || previousHash == hash {
+		// Erste Abfrage legt nur den Ausgangsstand fest
+		p.mu.Unlock()
+		return
+	}
+	p.lastChange = p.lastPoll
+	p.mu.Unlock()
+
+	event := Event{Poller: p.cfg.Name, URL: url, Time: p.clock.Now(), Status: status, Hash: hash, PreviousHash: previousHash}
+	if p.cfg.Detect == data.DetectDiff {
+		event.Changes = diffJSON(previousBody, body)
+	}
+
+	logger.Log.Info("Änderung erkannt:", zap.String("poller", p.cfg.Name), zap.Strings("changes", event.Changes))
+	if err := p.sink.Emit(event); err != nil {
+		logger.Log.Error("Fehler beim Ausliefern des Ereignisses:", zap.String("poller", p.cfg.Name), zap.Error(err))
+	}
+}
+
+func (p *poller) fail(err error) {
+	logger.Log.Warn("Fehler bei wiederkehrender Abfrage:", zap.String("poller", p.cfg.Name), zap.Error(err))
+
+	p.mu.Lock()
+	p.lastPoll = p.clock.Now()
+	p.lastError = err.Error()
+	p.mu.Unlock()
+}
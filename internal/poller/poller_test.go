@@ -0,0 +1,194 @@
+package poller_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// Der Logger wird nur einmal gesetzt, da gestoppte Poller ihn noch verwenden
+// können.
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// statusServer liefert den jeweils mit set gesetzten Body.
+func statusServer(t *testing.T, body string) (url string, set func(string)) {
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, func(next string) {
+		mu.Lock()
+		body = next
+		mu.Unlock()
+	}
+}
+
+// startPoller startet einen einzelnen Poller. Der erste Poller eines Managers
+// fragt sofort ab und legt damit den Ausgangsstand fest.
+func startPoller(t *testing.T, cfg data.PollerConfig, baseURL string) (*poller.Manager, *clock.Fake) {
+	current := &data.CurrentConfig{Name: "poller-target", BaseURL: baseURL}
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+	m := poller.NewManager(current)
+	assert.NoError(t, m.Start(cfg, false))
+	t.Cleanup(m.StopAll)
+
+	fake.BlockUntil(1)
+	return m, fake
+}
+
+// poll spult bis zur nächsten Abfrage vor und wartet, bis sie abgeschlossen ist.
+func poll(fake *clock.Fake) {
+	wait, _ := fake.NextWait()
+	fake.Advance(wait)
+	fake.BlockUntil(1)
+}
+
+func TestPollerDetectsChanges(t *testing.T) {
+	url, set := statusServer(t, `{"a": 1, "b": [1, 2]}`)
+
+	hashes, hashClock := startPoller(t, data.PollerConfig{Name: "hash", Endpoint: "/status", Interval: time.Minute}, url)
+	hashEvents := hashes.Events.Subscribe()
+	diffs, diffClock := startPoller(t, data.PollerConfig{Name: "diff", Endpoint: "/status", Interval: time.Minute, Detect: data.DetectDiff}, url)
+	diffEvents := diffs.Events.Subscribe()
+
+	// Unveränderte Antwort: kein Ereignis
+	poll(diffClock)
+	assert.Empty(t, diffEvents)
+
+	set(`{"a": 2, "b": [1], "c": true}`)
+	poll(diffClock)
+	if assert.Len(t, diffEvents, 1) {
+		event := <-diffEvents
+		assert.Equal(t, "diff", event.Poller)
+		assert.Equal(t, url+"/status", event.URL)
+		assert.Equal(t, []string{"$.a", "$.b[1]", "$.c"}, event.Changes)
+		assert.NotEqual(t, event.PreviousHash, event.Hash)
+		status := diffs.List()[0]
+		assert.Equal(t, event.Hash, status.Hash)
+		assert.Equal(t, diffClock.Now(), status.LastChange)
+	}
+
+	// Erkennung per Hash vergleicht nur den Inhalt, auch wenn er kein JSON ist
+	set("kein json")
+	poll(hashClock)
+	if assert.Len(t, hashEvents, 1) {
+		event := <-hashEvents
+		assert.Equal(t, "hash", event.Poller)
+		assert.Empty(t, event.Changes)
+		assert.NotEmpty(t, event.PreviousHash)
+	}
+	poll(hashClock)
+	assert.Empty(t, hashEvents)
+}
+
+func TestPollerSinks(t *testing.T) {
+	url, set := statusServer(t, `{"state": "a"}`)
+
+	var received []poller.Event
+	var contentType string
+	var mu sync.Mutex
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event poller.Event
+		json.Unmarshal(body, &event)
+		mu.Lock()
+		received = append(received, event)
+		contentType = r.Header.Get("Content-Type")
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	_, webhookClock := startPoller(t, data.PollerConfig{Name: "webhook", Endpoint: "/status", Interval: time.Minute, Sink: data.SinkConfig{Type: data.SinkWebhook, URL: webhook.URL}}, url)
+	_, fileClock := startPoller(t, data.PollerConfig{Name: "file", Endpoint: "/status", Interval: time.Minute, Sink: data.SinkConfig{Type: data.SinkFile, Path: file}}, url)
+
+	set(`{"state": "b"}`)
+	poll(webhookClock)
+	poll(fileClock)
+	set(`{"state": "c"}`)
+	poll(fileClock)
+
+	mu.Lock()
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, "webhook", received[0].Poller)
+	}
+	assert.Equal(t, "application/json", contentType)
+	mu.Unlock()
+
+	// Jedes Ereignis als eigene Zeile
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if assert.Len(t, lines, 2) {
+		var first, second poller.Event
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+		assert.Equal(t, first.Hash, second.PreviousHash)
+	}
+
+	// Unvollständige Sinks werden beim Start abgelehnt
+	m := poller.NewManager(&data.CurrentConfig{})
+	assert.Error(t, m.Start(data.PollerConfig{Name: "x", Endpoint: "/x", Interval: time.Minute, Sink: data.SinkConfig{Type: data.SinkWebhook}}, false))
+	assert.Error(t, m.Start(data.PollerConfig{Name: "x", Endpoint: "/x", Interval: time.Minute, Sink: data.SinkConfig{Type: data.SinkFile}}, false))
+	assert.Error(t, m.Start(data.PollerConfig{Name: "x", Endpoint: "/x", Interval: time.Minute, Sink: data.SinkConfig{Type: "kafka"}}, false))
+}
+
+func TestPollerReload(t *testing.T) {
+	yearly := func(name, endpoint string) data.PollerConfig {
+		return data.PollerConfig{Name: name, Endpoint: endpoint, Cron: "@yearly"}
+	}
+	current := &data.CurrentConfig{Name: "poller-target", Pollers: []data.PollerConfig{
+		yearly("keep", "/a"), yearly("change", "/b"), yearly("drop", "/c"),
+	}}
+	config.SetClock(current, clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC)))
+	m := poller.NewManager(current)
+	defer m.StopAll()
+
+	m.Reload(current)
+	assert.NoError(t, m.Start(yearly("api", "/d"), true))
+	m.Load([]poller.Snapshot{
+		{Config: yearly("keep", "/a"), LastHash: "keep-hash"},
+		{Config: yearly("change", "/b"), LastHash: "change-hash"},
+	})
+
+	// Geänderter Poller mit gleichem Endpoint behält seinen Stand, über die API
+	// angelegte bleiben unberührt
+	changed := yearly("change", "/b")
+	changed.Cron = "@daily"
+	next := *current
+	next.Pollers = []data.PollerConfig{yearly("keep", "/a"), changed, yearly("new", "/e")}
+	m.Reload(&next)
+
+	var names, hashes []string
+	for _, status := range m.List() {
+		names = append(names, status.Config.Name)
+		hashes = append(hashes, status.Hash)
+	}
+	assert.Equal(t, []string{"api", "change", "keep", "new"}, names)
+	assert.Equal(t, []string{"", "change-hash", "keep-hash", ""}, hashes)
+	assert.Equal(t, "@daily", m.List()[1].Config.Cron)
+	assert.True(t, m.List()[0].Dynamic)
+}
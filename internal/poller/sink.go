@@ -0,0 +1,141 @@
+package poller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+)
+
+// Event beschreibt eine erkannte Änderung einer abgefragten Ressource.
+type Event struct {
+	Poller       string    `json:"poller"`
+	URL          string    `json:"url"`
+	Time         time.Time `json:"time"`
+	Status       int       `json:"status"`
+	Hash         string    `json:"hash"`
+	PreviousHash string    `json:"previous_hash,omitempty"`
+	Changes      []string  `json:"changes,omitempty"`
+}
+
+// Sink nimmt Änderungsereignisse entgegen.
+type Sink interface {
+	Emit(event Event) error
+}
+
+func buildSink(cfg data.SinkConfig, broker *Broker) (Sink, error) {
+	switch cfg.Type {
+	case data.SinkWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook-Sink benötigt eine url")
+		}
+		return &webhookSink{url: cfg.URL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case data.SinkFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("file-Sink benötigt einen path")
+		}
+		return &fileSink{path: cfg.Path}, nil
+	case data.SinkSSE, "":
+		return broker, nil
+	default:
+		return nil, fmt.Errorf("unbekannter Sink-Typ: %s", cfg.Type)
+	}
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookSink) Emit(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Wavely/1.0")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook antwortet mit %s", resp.Status)
+	}
+	return nil
+}
+
+// fileSink hängt jedes Ereignis als JSON-Zeile an eine Datei an.
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (f *fileSink) Emit(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Broker verteilt Ereignisse an alle verbundenen Server-Sent-Events-Clients.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan Event]struct{})}
+}
+
+func (b *Broker) Subscribe() chan Event {
+	ch := make(chan Event, 16)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *Broker) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// Emit verwirft Ereignisse für Clients, die nicht schnell genug lesen.
+func (b *Broker) Emit(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"context"
+	"sync"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+type signal int
+
+const (
+	signalCancel signal = iota + 1
+	// Backoff abbrechen und mit zurückgesetzten Versuchen sofort erneut versuchen
+	signalRetry
+)
+
+// Signale an Jobs, die gerade von einem Worker bearbeitet werden.
+var signals = struct {
+	sync.Mutex
+	jobs map[string]watcher
+}{jobs: make(map[string]watcher)}
+
+type watcher struct {
+	ch chan signal
+	// Beendet Wartezeiten des Jobs bei Abbruch und beim Herunterfahren
+	cancel context.CancelFunc
+}
+
+// watch meldet einen Job für Signale an. Der gelieferte Kontext endet, sobald
+// der Job abgebrochen oder die Verarbeitung heruntergefahren wird.
+func watch(id string) (chan signal, context.Context) {
+	signals.Lock()
+	defer signals.Unlock()
+
+	ctx, cancel := context.WithCancel(stopCtx)
+	ch := make(chan signal, 1)
+	signals.jobs[id] = watcher{ch: ch, cancel: cancel}
+	return ch, ctx
+}
+
+func unwatch(id string) {
+	signals.Lock()
+	defer signals.Unlock()
+
+	if w, ok := signals.jobs[id]; ok {
+		w.cancel()
+		delete(signals.jobs, id)
+	}
+}
+
+// notify stellt dem Worker eines Jobs ein Signal zu. Ein Abbruch hat Vorrang
+// vor einem noch nicht abgeholten Retry. Liefert false, wenn der Job gerade
+// nicht bearbeitet wird.
+func notify(id string, s signal) bool {
+	signals.Lock()
+	defer signals.Unlock()
+
+	w, ok := signals.jobs[id]
+	if !ok {
+		return false
+	}
+	if s == signalCancel {
+		w.cancel()
+	}
+	select {
+	case w.ch <- s:
+	default:
+		if s == signalCancel {
+			<-w.ch
+			w.ch <- s
+		}
+	}
+	return true
+}
+
+// CancelJob entfernt einen ausstehenden Job. Ein Worker bricht die Bearbeitung
+// vor dem nächsten Versuch ab; ein bereits laufender Versuch wird nur beim
+// Warten auf das Rate-Limit unterbrochen. Ein noch nicht fälliger Job gibt
+// seinen Platz in der Queue sofort frei. Wartende Nachfolger mit derselben UID
+// rücken nach. Der Aufrufer muss jobMutex halten.
+func CancelJob(id string, pendingJobs *[]data.PendingJob) (data.PendingJob, bool) {
+	index := -1
+	for i, j := range *pendingJobs {
+		if j.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return data.PendingJob{}, false
+	}
+
+	cancelled := (*pendingJobs)[index]
+	*pendingJobs = append((*pendingJobs)[:index], (*pendingJobs)[index+1:]...)
+	notify(id, signalCancel)
+	Delayed.Remove(id)
+
+	for i, j := range *pendingJobs {
+		if j.After != id {
+			continue
+		}
+		// Der Nachfolger wartet nun auf den Vorgänger des abgebrochenen Jobs
+		(*pendingJobs)[i].After = cancelled.After
+		if cancelled.After == "" {
+			logger.Log.Info("Wartender Job freigegeben:", zap.String("id", j.ID), zap.String("uid", j.Job.UID))
+			Submit((*pendingJobs)[i], true)
+		}
+		break
+	}
+
+	logger.Log.Info("Job abgebrochen:", zap.String("id", id), zap.String("uid", cancelled.Job.UID))
+	return cancelled, true
+}
+
+// RequeueJob setzt die Versuche eines ausstehenden Jobs zurück. Wartet der Job
+// gerade im Backoff, wird sofort ein neuer Versuch gestartet. Liefert den Job
+// und ob er gerade bearbeitet wird. Der Aufrufer muss jobMutex halten.
+func RequeueJob(id string, pendingJobs *[]data.PendingJob) (data.PendingJob, bool, bool) {
+	for i, j := range *pendingJobs {
+		if j.ID != id {
+			continue
+		}
+		(*pendingJobs)[i].Attempts = 0
+		active := notify(id, signalRetry)
+		logger.Log.Info("Job erneut eingeplant:", zap.String("id", id), zap.String("uid", j.Job.UID), zap.Bool("active", active))
+		return (*pendingJobs)[i], active, true
+	}
+	return data.PendingJob{}, false, false
+}
+
+// isPending meldet, ob der Job noch aussteht, also nicht abgebrochen wurde.
+func isPending(job data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex) bool {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for _, j := range *pendingJobs {
+		if j.ID == job.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Delayed hält Jobs mit run_at/not_before in einem Min-Heap, bis sie fällig sind.
+// Ein einzelner Timer weckt den Verteiler, statt pro Job eine Goroutine schlafen
+// zu lassen. Die Uhr wird von StartWorkerPool aus der Konfiguration übernommen.
+var Delayed = newDelayQueue()
+
+type delayQueue struct {
+	mu    sync.Mutex
+	jobs  delayHeap
+	wake  chan struct{}
+	clock clock.Clock
+}
+
+func newDelayQueue() *delayQueue {
+	return &delayQueue{wake: make(chan struct{}, 1)}
+}
+
+// Add plant einen Job für seinen Fälligkeitszeitpunkt ein. Der Job belegt ab
+// sofort einen Platz in der Queue; ist keiner frei, liefert Add false. Mit force
+// wird der Job auch über die Kapazität hinaus angenommen.
+func (d *delayQueue) Add(job data.PendingJob, force bool) bool {
+	if !JobQueue.reserve(force) {
+		return false
+	}
+
+	d.mu.Lock()
+	heap.Push(&d.jobs, job)
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Remove entfernt einen noch nicht fälligen Job und gibt seinen Platz in der
+// Queue frei. Liefert false, wenn der Job nicht (mehr) im Heap steht.
+func (d *delayQueue) Remove(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, job := range d.jobs {
+		if job.ID == id {
+			heap.Remove(&d.jobs, i)
+			JobQueue.unreserve()
+			return true
+		}
+	}
+	return false
+}
+
+// now liefert die aktuelle Zeit der Uhr, gegen die Fälligkeiten geprüft werden.
+func (d *delayQueue) now() time.Time {
+	return clock.Or(d.clock).Now()
+}
+
+func (d *delayQueue) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.jobs.Len()
+}
+
+// run übergibt fällige Jobs an die Queue, auf den bei Add reservierten Platz.
+func (d *delayQueue) run() {
+	clk := clock.Or(d.clock)
+
+	for {
+		d.mu.Lock()
+		now := clk.Now()
+		for d.jobs.Len() > 0 && !d.jobs[0].Job.DueAt().After(now) {
+			job := heap.Pop(&d.jobs).(data.PendingJob)
+			logger.Log.Debug("Geplanter Job ist fällig:", zap.String("id", job.ID), zap.String("uid", job.Job.UID))
+			JobQueue.pushReserved(job)
+		}
+		wait := time.Hour
+		if d.jobs.Len() > 0 {
+			wait = d.jobs[0].Job.DueAt().Sub(now)
+		}
+		d.mu.Unlock()
+
+		select {
+		case <-clk.After(wait):
+		case <-d.wake:
+		}
+	}
+}
+
+type delayHeap []data.PendingJob
+
+func (h delayHeap) Len() int           { return len(h) }
+func (h delayHeap) Less(i, j int) bool { return h[i].Job.DueAt().Before(h[j].Job.DueAt()) }
+func (h delayHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *delayHeap) Push(x any) { *h = append(*h, x.(data.PendingJob)) }
+
+func (h *delayHeap) Pop() any {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	*h = old[:n-1]
+	return job
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayedJobs(t *testing.T) {
+	queue, delayed := JobQueue, Delayed
+	defer func() { JobQueue, Delayed = queue, delayed }()
+
+	start := time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	JobQueue = NewScheduler(2, nil)
+	Delayed = newDelayQueue()
+	Delayed.clock = fake
+
+	job := func(id string, runAt, notBefore time.Duration) data.PendingJob {
+		j := data.PendingJob{ID: id, CreatedAt: start}
+		if runAt > 0 {
+			j.Job.RunAt = start.Add(runAt)
+		}
+		if notBefore > 0 {
+			j.Job.NotBefore = start.Add(notBefore)
+		}
+		return j
+	}
+
+	// Geplante Jobs belegen ihren Platz bereits bei der Annahme
+	assert.NoError(t, Submit(job("run-at", 2*time.Minute, 0), false))
+	assert.NoError(t, Submit(job("not-before", 0, time.Minute), false))
+	assert.Equal(t, 0, FreeSlots())
+	assert.ErrorIs(t, Submit(job("later", 3*time.Minute, 0), false), ErrQueueFull)
+	assert.ErrorIs(t, Submit(job("now", 0, 0), false), ErrQueueFull)
+	assert.Equal(t, 2, Delayed.Len())
+	assert.Equal(t, 0, JobQueue.Len())
+
+	// Der Heap liefert in der Reihenfolge der Fälligkeit
+	go Delayed.run()
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	assert.Equal(t, "not-before", JobQueue.Pop().ID)
+	assert.Equal(t, 1, FreeSlots())
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	assert.Equal(t, "run-at", JobQueue.Pop().ID)
+	assert.Equal(t, 2, FreeSlots())
+	assert.Equal(t, 0, Delayed.Len())
+
+	// Nach einem Neustart warten wiederhergestellte Jobs erneut auf ihren Zeitpunkt
+	restored := job("restored", 0, 10*time.Minute)
+	immediate := job("immediate", 0, 0)
+	immediate.CreatedAt = start.Add(time.Second)
+	ScheduleRestored([]data.PendingJob{immediate, restored}, 0)
+	assert.Equal(t, "immediate", JobQueue.Pop().ID)
+	assert.Equal(t, 1, Delayed.Len())
+
+	// Die Wartezeit auf restored kommt zu der des leeren Heaps hinzu
+	fake.BlockUntil(2)
+	fake.Advance(8 * time.Minute)
+	assert.Equal(t, "restored", JobQueue.Pop().ID)
+}
+
+func TestCancelDelayedJob(t *testing.T) {
+	queue, delayed := JobQueue, Delayed
+	defer func() { JobQueue, Delayed = queue, delayed }()
+
+	start := time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC)
+	JobQueue = NewScheduler(1, nil)
+	Delayed = newDelayQueue()
+	Delayed.clock = clock.NewFake(start)
+
+	job := data.PendingJob{ID: "planned", CreatedAt: start, Job: data.Job{RunAt: start.Add(time.Hour)}}
+	assert.NoError(t, Submit(job, false))
+	assert.Equal(t, 0, FreeSlots())
+
+	// Der Abbruch entfernt den Job aus dem Heap und gibt seinen Platz frei
+	pending := []data.PendingJob{job}
+	_, ok := CancelJob(job.ID, &pending)
+	assert.True(t, ok)
+	assert.Equal(t, 0, Delayed.Len())
+	assert.Equal(t, 1, FreeSlots())
+	assert.NoError(t, Submit(data.PendingJob{ID: "now", CreatedAt: start}, false))
+	assert.Equal(t, "now", JobQueue.Pop().ID)
+}
@@ -0,0 +1,243 @@
+package processor
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+// LeaseFile hält die gerade gehaltenen Sperren fest, damit sie nach einem
+// Absturz beim nächsten Start freigegeben werden können (leer: kein Journal).
+var LeaseFile string
+
+// lockInfo ist die gehaltene Sperre in den Templates (.Lock.Token).
+type lockInfo struct {
+	Token string
+}
+
+// lease ist eine gehaltene Sperre, wie sie im Journal steht.
+type lease struct {
+	JobID    string    `json:"job_id"`
+	Target   string    `json:"target"`
+	UID      string    `json:"uid"`
+	Token    string    `json:"token"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// Gehaltene Sperren aller Worker nach Job-ID.
+var leases = struct {
+	sync.Mutex
+	held map[string]lease
+}{held: make(map[string]lease)}
+
+// heldLock ist die Sperre eines laufenden Versuchs samt Verlängerung.
+type heldLock struct {
+	lease
+	currentCfg *data.CurrentConfig
+	stop       chan struct{}
+	renewed    sync.WaitGroup
+	lost       atomic.Bool
+}
+
+// acquireLock erwirbt die Sperre für einen Versuch und verlängert sie bis zur
+// Freigabe. Wird die Sperre nicht gewährt, ist das Target nicht schreibbar.
+func acquireLock(job *data.PendingJob, currentCfg *data.CurrentConfig) (*heldLock, error) {
+	cfg := &currentCfg.Lock
+	ctx, err := newStepContext(job, "", currentCfg)
+	if err != nil {
+		return nil, err
+	}
+	acquire := cfg.Acquire.Step("lock")
+	acquire.Method = cmp.Or(acquire.Method, http.MethodPost)
+	resp, err := request(acquire, ctx, &job.Job, currentCfg)
+	if err != nil {
+		return nil, err
+	}
+	value, err := extract(resp, cfg.Token)
+	if err != nil {
+		return nil, fmt.Errorf("Token: %w", err)
+	}
+	token := text(value)
+	if token == "" {
+		return nil, fmt.Errorf("Token %s ist leer", cfg.Token)
+	}
+
+	l := &heldLock{
+		lease: lease{
+			JobID:    job.ID,
+			Target:   currentCfg.Name,
+			UID:      job.Job.UID,
+			Token:    token,
+			Acquired: clock.Or(currentCfg.Clock).Now(),
+		},
+		currentCfg: currentCfg,
+		stop:       make(chan struct{}),
+	}
+	hold(l.lease)
+	logger.Log.Debug("Sperre erworben:", zap.String("uid", l.UID), zap.String("token", token))
+
+	if cfg.Lease > 0 && cfg.Renew.Endpoint != "" {
+		l.renewed.Add(1)
+		go l.renew()
+	}
+	return l, nil
+}
+
+// info liefert die Sperre für die Templates.
+func (l *heldLock) info() lockInfo {
+	if l == nil {
+		return lockInfo{}
+	}
+	return lockInfo{Token: l.Token}
+}
+
+// renew verlängert die Lease jeweils nach der Hälfte ihrer Laufzeit. Schlägt
+// eine Verlängerung fehl, gilt die Sperre als verloren.
+func (l *heldLock) renew() {
+	defer l.renewed.Done()
+	cfg := &l.currentCfg.Lock
+	clk := clock.Or(l.currentCfg.Clock)
+
+	for {
+		select {
+		case <-clk.After(cfg.Lease / 2):
+		case <-l.stop:
+			return
+		}
+		renew := cfg.Renew.Step("lock")
+		renew.Method = cmp.Or(renew.Method, http.MethodPut)
+		// Ohne Job: Wartezeiten am Rate-Limiter zählen nur für den laufenden Versuch
+		if _, err := request(renew, &stepContext{Job: data.Job{UID: l.UID}, Lock: l.info()}, nil, l.currentCfg); err != nil {
+			logger.Log.Error("Sperre konnte nicht verlängert werden:", zap.String("uid", l.UID), zap.Error(err))
+			l.lost.Store(true)
+			return
+		}
+		logger.Log.Debug("Sperre verlängert:", zap.String("uid", l.UID))
+	}
+}
+
+// lostLease meldet, ob die Sperre während des Versuchs verloren ging.
+func (l *heldLock) lostLease() bool {
+	return l != nil && l.lost.Load()
+}
+
+// release beendet die Verlängerung und gibt die Sperre frei.
+func (l *heldLock) release() {
+	if l == nil {
+		return
+	}
+	close(l.stop)
+	l.renewed.Wait()
+	releaseLease(l.lease, l.currentCfg)
+}
+
+// releaseLease gibt eine Sperre frei. Scheitert die Freigabe, bleibt die Sperre
+// im Journal und wird beim Herunterfahren bzw. nächsten Start erneut freigegeben.
+func releaseLease(l lease, currentCfg *data.CurrentConfig) bool {
+	release := currentCfg.Lock.Release.Step("lock")
+	release.Method = cmp.Or(release.Method, http.MethodDelete)
+	if _, err := request(release, &stepContext{Job: data.Job{UID: l.UID}, Lock: lockInfo{Token: l.Token}}, nil, currentCfg); err != nil {
+		logger.Log.Error("Sperre konnte nicht freigegeben werden:", zap.String("uid", l.UID), zap.Error(err))
+		return false
+	}
+	unhold(l.JobID)
+	logger.Log.Debug("Sperre freigegeben:", zap.String("uid", l.UID))
+	return true
+}
+
+// ReleaseLocks gibt alle noch gehaltenen Sperren frei, etwa wenn beim
+// Herunterfahren die Frist für laufende Versuche abgelaufen ist.
+func ReleaseLocks(currentCfg *data.CurrentConfig) {
+	leases.Lock()
+	held := slices.Collect(maps.Values(leases.held))
+	leases.Unlock()
+
+	for _, l := range held {
+		releaseLease(l, currentCfg)
+	}
+}
+
+// RecoverLocks gibt nach einem Absturz die Sperren aus dem Journal frei, damit
+// das Target nicht bis zum Ablauf der Leases blockiert bleibt. Sperren anderer
+// Targets werden verworfen. Muss vor dem Start der Worker aufgerufen werden.
+func RecoverLocks(currentCfg *data.CurrentConfig) {
+	if LeaseFile == "" {
+		return
+	}
+	content, err := os.ReadFile(LeaseFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	var stale []lease
+	if err == nil {
+		err = json.Unmarshal(content, &stale)
+	}
+	if err != nil {
+		logger.Log.Error("Fehler beim Lesen der gehaltenen Sperren:", zap.String("filename", LeaseFile), zap.Error(err))
+		return
+	}
+
+	logger.Log.Info("Verwaiste Sperren werden freigegeben:", zap.String("filename", LeaseFile), zap.Int("count", len(stale)))
+	for _, l := range stale {
+		if l.Target != currentCfg.Name || currentCfg.Lock.Release.Endpoint == "" {
+			logger.Log.Warn("Sperre verworfen, Target unbekannt oder ohne Sperrprotokoll:", zap.String("target", l.Target), zap.String("uid", l.UID))
+			continue
+		}
+		// Bis zur erfolgreichen Freigabe im Journal behalten
+		leases.Lock()
+		leases.held[l.JobID] = l
+		leases.Unlock()
+		releaseLease(l, currentCfg)
+	}
+	saveLeases()
+}
+
+func hold(l lease) {
+	leases.Lock()
+	leases.held[l.JobID] = l
+	leases.Unlock()
+	saveLeases()
+}
+
+func unhold(jobID string) {
+	leases.Lock()
+	delete(leases.held, jobID)
+	leases.Unlock()
+	saveLeases()
+}
+
+// saveLeases schreibt das Journal; ohne gehaltene Sperren wird es entfernt.
+func saveLeases() {
+	if LeaseFile == "" {
+		return
+	}
+	leases.Lock()
+	defer leases.Unlock()
+
+	if len(leases.held) == 0 {
+		if err := os.Remove(LeaseFile); err != nil && !os.IsNotExist(err) {
+			logger.Log.Error("Fehler beim Entfernen der gehaltenen Sperren:", zap.String("filename", LeaseFile), zap.Error(err))
+		}
+		return
+	}
+	held := slices.SortedFunc(maps.Values(leases.held), func(a, b lease) int { return a.Acquired.Compare(b.Acquired) })
+	content, err := json.MarshalIndent(held, "", "  ")
+	if err == nil {
+		err = os.WriteFile(LeaseFile, content, 0644)
+	}
+	if err != nil {
+		logger.Log.Error("Fehler beim Speichern der gehaltenen Sperren:", zap.String("filename", LeaseFile), zap.Error(err))
+	}
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"cmp"
+	"fmt"
+
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/tmpl"
+)
+
+// targetPayload liefert den Payload eines Jobs im content_type des Targets. Jobs
+// ohne content_type gelten als bereits im Format des Targets eingereicht.
+func targetPayload(job data.Job, payload []byte, currentCfg *data.CurrentConfig) ([]byte, error) {
+	from := cmp.Or(job.ContentType, currentCfg.ContentType)
+	to := cmp.Or(currentCfg.ContentType, from)
+	return convert.Convert(payload, from, to, currentCfg.Convert)
+}
+
+// payloadValue dekodiert einen Payload im angegebenen Format (Standard: JSON).
+// Payloads, die sich nicht dekodieren lassen, haben keinen Wert (nil).
+func payloadValue(payload []byte, format string, currentCfg *data.CurrentConfig) any {
+	value, err := convert.Decode(payload, cmp.Or(format, convert.JSON), currentCfg.Convert)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// Transform liefert den Payload, wie er an das Target geschrieben wird: in den
+// content_type des Targets umgewandelt und ggf. durch das Template transform
+// umgeformt. revision ist die vor dem Schreiben ermittelte Revision.
+func Transform(job data.PendingJob, revision string, currentCfg *data.CurrentConfig) ([]byte, error) {
+	ctx, err := newStepContext(&job, revision, currentCfg)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ctx.Payload()
+	return []byte(payload), err
+}
+
+// transform rendert das Template transform über dem Kontext eines Versuchs.
+func transform(ctx *stepContext, currentCfg *data.CurrentConfig) (string, error) {
+	if currentCfg.Transform == "" || currentCfg.ParsedTransform == nil {
+		return ctx.converted, nil
+	}
+	out, err := tmpl.Render(currentCfg.ParsedTransform, "transform", transformContext{ctx, ctx.converted})
+	if err != nil {
+		return "", fmt.Errorf("Transformation: %w", err)
+	}
+	return out, nil
+}
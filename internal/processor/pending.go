@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"sync"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+// updateProgress überträgt Versuche, Backoff-Phase und Wartezeiten in pendingJobs, damit sie
+// beim Persistieren erhalten bleiben.
+func updateProgress(job data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex) {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for i, j := range *pendingJobs {
+		if j.ID == job.ID {
+			(*pendingJobs)[i].Attempts = job.Attempts
+			(*pendingJobs)[i].PhaseShift = job.PhaseShift
+			(*pendingJobs)[i].Jitter = job.Jitter
+			(*pendingJobs)[i].RateLimitWait = job.RateLimitWait
+			return
+		}
+	}
+}
+
+// loadProgress übernimmt die Versuche aus pendingJobs. Ein Requeue setzt sie nur
+// dort zurück, auch für Jobs, die noch in der Queue standen oder wiederhergestellt
+// wurden und daher mit einem älteren Stand übergeben werden.
+func loadProgress(job *data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex) {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for _, j := range *pendingJobs {
+		if j.ID == job.ID {
+			job.Attempts = j.Attempts
+			return
+		}
+	}
+}
+
+// refreshPayload übernimmt einen zwischenzeitlich ersetzten Payload (Policy "replace").
+func refreshPayload(job *data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex) {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for _, j := range *pendingJobs {
+		if j.ID == job.ID {
+			job.Job.Data = j.Job.Data
+			job.Job.ContentType = j.Job.ContentType
+			job.Version = j.Version
+			return
+		}
+	}
+}
+
+// completeJob entfernt einen erfolgreich geschriebenen Job und gibt einen in der
+// Warteschlange stehenden Nachfolger mit derselben UID frei. Wurde der Payload
+// während des Schreibens ersetzt, bleibt der Job bestehen und es wird false geliefert.
+func completeJob(job data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex) bool {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for i, j := range *pendingJobs {
+		if j.ID != job.ID {
+			continue
+		}
+		if j.Version != job.Version {
+			return false
+		}
+		*pendingJobs = append((*pendingJobs)[:i], (*pendingJobs)[i+1:]...) // Job entfernen
+		break
+	}
+	throughput.record()
+
+	for i, j := range *pendingJobs {
+		if j.After == job.ID {
+			(*pendingJobs)[i].After = ""
+			next := (*pendingJobs)[i]
+			logger.Log.Info("Wartender Job freigegeben:", zap.String("id", next.ID), zap.String("uid", next.Job.UID))
+			Submit(next, true)
+			break
+		}
+	}
+	return true
+}
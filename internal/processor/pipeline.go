@@ -0,0 +1,225 @@
+package processor
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/external"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/tmpl"
+	"go.uber.org/zap"
+)
+
+// stepContext sind die Daten für die Templates der Pipeline und der
+// Transformation: die Felder des Jobs (.UID, .Data, ...), Angaben zum
+// ausstehenden Job (.Meta.ID, .Meta.Attempts, ...), die vor dem Schreiben
+// ermittelte Revision, der Payload im content_type des Targets, der dekodierte
+// eingereichte Payload, die Ausgaben bisheriger Schritte und die gehaltene Sperre.
+type stepContext struct {
+	data.Job
+	Meta     jobMeta
+	Revision string
+	Value    any
+	Steps    map[string]map[string]any
+	Lock     lockInfo
+
+	// Payload im content_type des Targets, vor der Transformation
+	converted  string
+	currentCfg *data.CurrentConfig
+}
+
+// Payload liefert den transformierten Payload. Er wird erst bei Verwendung
+// gerendert, damit die Transformation die Ausgaben bisheriger Schritte sieht.
+func (c *stepContext) Payload() (string, error) {
+	return transform(c, c.currentCfg)
+}
+
+// transformContext ist der Kontext der Transformation selbst. Dort ist .Payload
+// der Payload im content_type des Targets, noch nicht transformiert.
+type transformContext struct {
+	*stepContext
+	Payload string
+}
+
+// jobMeta sind die Verwaltungsdaten eines ausstehenden Jobs.
+type jobMeta struct {
+	ID        string
+	Target    string
+	Client    string
+	Attempts  int
+	CreatedAt time.Time
+}
+
+func newStepContext(job *data.PendingJob, revision string, currentCfg *data.CurrentConfig) (*stepContext, error) {
+	submitted, err := base64.StdEncoding.DecodeString(job.Job.Data)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := targetPayload(job.Job, submitted, currentCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &stepContext{
+		Job:        job.Job,
+		Meta:       jobMeta{ID: job.ID, Target: cmp.Or(job.Target, currentCfg.Name), Client: job.Client, Attempts: job.Attempts, CreatedAt: job.CreatedAt},
+		Revision:   revision,
+		Value:      payloadValue(submitted, cmp.Or(job.Job.ContentType, currentCfg.ContentType), currentCfg),
+		Steps:      make(map[string]map[string]any),
+		converted:  string(payload),
+		currentCfg: currentCfg,
+	}, nil
+}
+
+// runPipeline führt die Schritte der Pipeline nacheinander aus. Nach einem
+// gescheiterten Schritt laufen nur noch Schritte mit always. Liefert true, wenn
+// kein Schritt mit on_failure retry gescheitert ist.
+func runPipeline(job *data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex, currentCfg *data.CurrentConfig, lock lockInfo) bool {
+	// Ggf. wurde der Payload inzwischen durch einen neueren Job ersetzt
+	refreshPayload(job, pendingJobs, jobMutex)
+
+	ctx, err := newStepContext(job, "", currentCfg)
+	if err != nil {
+		logger.Log.Error("Fehler beim Umwandeln des Payloads:", zap.String("id", job.ID), zap.Error(err))
+		return false
+	}
+	ctx.Lock = lock
+
+	failed := false
+	for _, step := range currentCfg.Pipeline {
+		if failed && !step.Always {
+			continue
+		}
+		err := runStep(step, ctx, &job.Job, currentCfg)
+		if err == nil {
+			continue
+		}
+		if step.OnFailure == data.OnFailureContinue {
+			logger.Log.Info("Pipeline-Schritt übergangen:", zap.String("step", step.Name), zap.String("uid", job.Job.UID), zap.Error(err))
+			continue
+		}
+		logger.Log.Error("Fehler im Pipeline-Schritt:", zap.String("step", step.Name), zap.String("uid", job.Job.UID), zap.Error(err))
+		failed = true
+	}
+	if !failed {
+		logger.Log.Info("Pipeline erfolgreich durchlaufen:", zap.String("uid", job.Job.UID))
+	}
+	return !failed
+}
+
+// runStep führt einen Schritt aus und legt seine Ausgaben unter ctx.Steps ab,
+// bevor die Bedingung geprüft wird.
+func runStep(step data.StepConfig, ctx *stepContext, job *data.Job, currentCfg *data.CurrentConfig) error {
+	resp, err := request(step, ctx, job, currentCfg)
+	if err != nil {
+		return err
+	}
+
+	outputs := make(map[string]any, len(step.Extract))
+	ctx.Steps[step.Name] = outputs
+	for name, source := range step.Extract {
+		value, err := extract(resp, source)
+		if err != nil {
+			return fmt.Errorf("Ausgabe %s: %w", name, err)
+		}
+		outputs[name] = value
+	}
+
+	if step.Success.Condition != "" {
+		result, err := tmpl.Render(step.Parsed, "condition", ctx)
+		if err != nil {
+			return fmt.Errorf("Bedingung: %w", err)
+		}
+		if strings.TrimSpace(result) != "true" {
+			return fmt.Errorf("Bedingung nicht erfüllt: %s", step.Success.Condition)
+		}
+	}
+	return nil
+}
+
+// request rendert den Aufruf eines Schritts, führt ihn aus und prüft den
+// Statuscode. Bei gehaltener Sperre wird das Token im Lock-Header mitgeschickt.
+func request(step data.StepConfig, ctx *stepContext, job *data.Job, currentCfg *data.CurrentConfig) (*external.Response, error) {
+	endpoint, err := tmpl.Render(step.Parsed, "endpoint", ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Endpunkt: %w", err)
+	}
+	var body []byte
+	if step.Body != "" {
+		rendered, err := tmpl.Render(step.Parsed, "body", ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Body: %w", err)
+		}
+		body = []byte(rendered)
+	}
+	header := make(http.Header)
+	if ctx.Lock.Token != "" && currentCfg.Lock.Header != "" {
+		header.Set(currentCfg.Lock.Header, ctx.Lock.Token)
+	}
+	for name := range step.Headers {
+		value, err := tmpl.Render(step.Parsed, "header:"+name, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Header %s: %w", name, err)
+		}
+		header.Set(name, value)
+	}
+
+	resp, err := external.Call(external.Request{
+		Method:   cmp.Or(strings.ToUpper(step.Method), http.MethodGet),
+		Endpoint: endpoint,
+		Header:   header,
+		Body:     body,
+		Limit:    cmp.Or(step.RateLimit, step.Name),
+	}, job, currentCfg)
+	if err != nil {
+		return nil, err
+	}
+	if !successStatus(step.Success.Status, resp.Status) {
+		return resp, fmt.Errorf("unerwarteter Status %d, Body: %s", resp.Status, resp.Body)
+	}
+	return resp, nil
+}
+
+func successStatus(expected []int, status int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(expected, status)
+}
+
+// extract liest eine Ausgabe aus der Antwort. Quellen sind status, body (roh),
+// body.<pfad> (JSON, Listenindizes als Zahl, z.B. body.items.0.id) und
+// header.<name>.
+func extract(resp *external.Response, source string) (any, error) {
+	switch {
+	case source == "status":
+		return resp.Status, nil
+	case source == "body":
+		return string(resp.Body), nil
+	case strings.HasPrefix(source, "header."):
+		name := strings.TrimPrefix(source, "header.")
+		if values := resp.Header.Values(name); len(values) > 0 {
+			return values[0], nil
+		}
+		return nil, fmt.Errorf("Header %s fehlt in der Antwort", name)
+	case strings.HasPrefix(source, "body."):
+		var value any
+		if err := json.Unmarshal(resp.Body, &value); err != nil {
+			return nil, fmt.Errorf("Antwort ist kein JSON: %w", err)
+		}
+		value, ok := convert.Path(value, strings.TrimPrefix(source, "body."))
+		if !ok {
+			return nil, fmt.Errorf("%s fehlt in der Antwort", source)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unbekannte Quelle %q", source)
+}
@@ -1,9 +1,11 @@
 package processor
 
 import (
+	"encoding/base64"
+	"net/http"
 	"sync"
-	"time"
 
+	"djp.chapter42.de/a/internal/clock"
 	"djp.chapter42.de/a/internal/data"
 	"djp.chapter42.de/a/internal/external"
 	"djp.chapter42.de/a/internal/logger"
@@ -12,47 +14,202 @@ import (
 )
 
 func ProcessJob(job data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex, currentCfg *data.CurrentConfig) {
-	backoff := timebackoff.NewSinusBackoff()
+	clk := clock.Or(currentCfg.Clock)
+	phases := timebackoff.AllocatorFor(currentCfg.Name)
+	backoff := backoffFor(&job, phases, currentCfg.Random)
+	signals, ctx := watch(job.ID)
+	defer unwatch(job.ID)
+	// Wartezeiten am Rate-Limiter enden wie alle Wartezeiten mit dem Kontext,
+	// Aufrufe erst mit Abort; die Wartezeit des letzten Versuchs verfällt, wenn
+	// der Job nicht mehr aussteht
+	external.Attach(&job.Job, ctx, abortCtx)
+	defer external.Detach(&job.Job)
+	// Erst nach watch lesen, damit kein Requeue dazwischen verloren geht
+	loadProgress(&job, pendingJobs, jobMutex)
 
-	for {
-		time.Sleep(backoff.CalculateBackoff(job.Attempts))
+	for retry := false; ; {
+		// Abgebrochene Jobs nicht weiter versuchen
+		if !isPending(job, pendingJobs, jobMutex) {
+			phases.Release(job.PhaseShift)
+			return
+		}
 
-		latestRevision, err := external.LatestRevision(&job.Job, currentCfg)
-		if err != nil {
-			logger.Log.Error("Konnte die neueste Revision nicht abrufen:", zap.String("uid", job.Job.UID), zap.Error(err))
-			job.Attempts++
-			continue
+		job.RateLimitWait += external.TakeRateLimitWait(&job.Job)
+		updateProgress(job, pendingJobs, jobMutex)
+		// Bei run_at erfolgt der erste Versuch direkt zum geplanten Zeitpunkt
+		// Nach einem Requeue wird ohne Backoff sofort versucht
+		if !retry && (job.Attempts > 0 || job.Job.RunAt.IsZero()) {
+			select {
+			case <-clk.After(backoff.CalculateBackoff(job.Attempts)):
+			case s := <-signals:
+				if s == signalRetry {
+					job.Attempts = 0
+					retry = true
+				}
+				continue
+			case <-stopCtx.Done():
+				// Beim Herunterfahren bleibt der Job ausstehend und wird persistiert
+				return
+			}
 		}
-		job.Job.UID = latestRevision
+		retry = false
 
-		writable, err := external.WriteCheck(&job.Job, currentCfg)
-		if err != nil {
-			logger.Log.Error("Fehler beim Überprüfen des Schreibzugriffs:", zap.String("uid", job.Job.UID), zap.Error(err))
-			job.Attempts++
+		// Während einer Sperrzeit warten, ohne einen Versuch zu zählen. Endet ein
+		// Fenster, wird erneut geprüft, falls direkt das nächste beginnt.
+		for {
+			waited := currentCfg.Calendar.Wait(ctx, job.Job.UID, job.PhaseShift)
+			if ctx.Err() != nil || !waited {
+				break
+			}
+		}
+		if Stopped() {
+			// Beim Herunterfahren bleibt der Job ausstehend und wird persistiert
+			return
+		}
+		if ctx.Err() != nil {
+			// Abgebrochen: wird zu Beginn der Schleife erkannt
 			continue
 		}
 
-		if writable {
-			err := external.WriteData(&job.Job, job.Job.Data, currentCfg)
-			if err != nil {
-				logger.Log.Error("Fehler beim Schreiben der Daten:", zap.String("uid", job.Job.UID), zap.Error(err))
-				job.Attempts++
-			} else {
-				logger.Log.Info("Daten erfolgreich geschrieben:", zap.String("uid", job.Job.UID))
-
-				jobMutex.Lock()
-				for i, j := range *pendingJobs {
-					if j.Job.UID == job.Job.UID {
-						*pendingJobs = append((*pendingJobs)[:i], (*pendingJobs)[i+1:]...) // Job entfernen
-						break
-					}
-				}
-				jobMutex.Unlock()
-
+		// Bei offenem Circuit Breaker parken, ebenfalls ohne einen Versuch zu zählen
+		if !currentCfg.Breaker.Wait(ctx, job.Job.UID) {
+			if Stopped() {
 				return
 			}
+			continue
+		}
+
+		// Pausierte oder geleerte Targets nicht versuchen, ohne einen Versuch zu zählen
+		if !awaitControls(currentCfg.Name, job.Job.UID) {
+			return
+		}
+
+		if !isPending(job, pendingJobs, jobMutex) {
+			continue
+		}
+		if !beginAttempt() {
+			return
+		}
+		done := attempt(&job, pendingJobs, jobMutex, currentCfg)
+		if done {
+			phases.Release(job.PhaseShift)
 		} else {
+			// Ergebnis verbuchen, bevor der Versuch beim Herunterfahren als beendet gilt
+			updateProgress(job, pendingJobs, jobMutex)
+		}
+		endAttempt()
+
+		if done {
+			return
+		}
+	}
+}
+
+// attempt führt einen Versuch aus, entweder über die Pipeline des Targets oder
+// den Standardablauf, ggf. unter einer Sperre des Targets, und prüft
+// anschließend das Geschriebene. Liefert true, wenn der Job abgeschlossen ist.
+func attempt(job *data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex, currentCfg *data.CurrentConfig) bool {
+	var lock *heldLock
+	if currentCfg.Lock.Enabled() {
+		var err error
+		if lock, err = acquireLock(job, currentCfg); err != nil {
+			logger.Log.Warn("Sperre nicht erhalten:", zap.String("uid", job.Job.UID), zap.Error(err))
 			job.Attempts++
+			return false
 		}
+		// Auch bei Fehlern, Abbruch und Herunterfahren freigeben
+		defer lock.release()
 	}
+
+	var before any
+	if currentCfg.Verify.Mode == data.VerifyRevision {
+		var err error
+		if before, err = revisionBefore(job, currentCfg); err != nil {
+			logger.Log.Error("Fehler beim Lesen der Revision vor dem Schreiben:", zap.String("uid", job.Job.UID), zap.Error(err))
+			job.Attempts++
+			return false
+		}
+	}
+
+	run := writeSteps
+	if len(currentCfg.Pipeline) > 0 {
+		run = runPipeline
+	}
+	if !run(job, pendingJobs, jobMutex, currentCfg, lock.info()) {
+		job.Attempts++
+		return false
+	}
+	if currentCfg.Verify.Mode != "" && !verifyWrite(job, before, currentCfg) {
+		job.Attempts++
+		return false
+	}
+	if lock.lostLease() {
+		logger.Log.Error("Sperre während des Schreibens verloren:", zap.String("uid", job.Job.UID))
+		job.Attempts++
+		return false
+	}
+
+	if !completeJob(*job, pendingJobs, jobMutex) {
+		logger.Log.Info("Payload wurde während des Schreibens ersetzt, schreibe erneut:", zap.String("id", job.ID))
+		return false
+	}
+	return true
+}
+
+// writeSteps ist der Standardablauf ohne Pipeline: Revision ermitteln,
+// Schreibbarkeit prüfen und schreiben. Unter einer Sperre entfällt die
+// Prüfung, das Token wird beim Schreiben mitgeschickt. Die Revision steht nur
+// in den Endpunkten von Prüfung und Schreiben; der Job behält seine UID.
+func writeSteps(job *data.PendingJob, pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex, currentCfg *data.CurrentConfig, lock lockInfo) bool {
+	latestRevision, err := external.LatestRevision(&job.Job, currentCfg)
+	if err != nil {
+		logger.Log.Error("Konnte die neueste Revision nicht abrufen:", zap.String("uid", job.Job.UID), zap.Error(err))
+		return false
+	}
+
+	header := make(http.Header)
+	if lock.Token != "" {
+		if currentCfg.Lock.Header != "" {
+			header.Set(currentCfg.Lock.Header, lock.Token)
+		}
+	} else {
+		writable, err := external.WriteCheckRevision(&job.Job, latestRevision, currentCfg)
+		if err != nil {
+			logger.Log.Error("Fehler beim Überprüfen des Schreibzugriffs:", zap.String("uid", job.Job.UID), zap.Error(err))
+			return false
+		}
+		if !writable {
+			return false
+		}
+	}
+
+	// Ggf. wurde der Payload inzwischen durch einen neueren Job ersetzt
+	refreshPayload(job, pendingJobs, jobMutex)
+
+	payload, err := Transform(*job, latestRevision, currentCfg)
+	if err != nil {
+		logger.Log.Error("Fehler beim Umwandeln des Payloads:", zap.String("uid", job.Job.UID), zap.Error(err))
+		return false
+	}
+	if err := external.WriteDataWithHeader(&job.Job, latestRevision, base64.StdEncoding.EncodeToString(payload), header, currentCfg); err != nil {
+		logger.Log.Error("Fehler beim Schreiben der Daten:", zap.String("uid", job.Job.UID), zap.Error(err))
+		return false
+	}
+	logger.Log.Info("Daten erfolgreich geschrieben:", zap.String("uid", job.Job.UID), zap.String("revision", latestRevision))
+	return true
+}
+
+// backoffFor setzt die Backoff-Kurve eines wiederhergestellten Jobs fort oder
+// vergibt für neue Jobs eine Phase, die sich gleichmäßig zwischen die übrigen
+// Jobs des Targets einfügt.
+func backoffFor(job *data.PendingJob, phases *timebackoff.PhaseAllocator, rng *clock.Random) *timebackoff.SinusBackoff {
+	if job.PhaseShift != 0 || job.Jitter != 0 {
+		phases.Reserve(job.PhaseShift)
+		return timebackoff.RestoreSinusBackoff(job.PhaseShift, job.Jitter)
+	}
+
+	backoff := timebackoff.NewAllocatedSinusBackoff(phases, rng)
+	job.PhaseShift = backoff.PhaseShift
+	job.Jitter = backoff.JitterFactor
+	return backoff
 }
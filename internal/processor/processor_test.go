@@ -0,0 +1,755 @@
+package processor_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/blackout"
+	"djp.chapter42.de/a/internal/breaker"
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/config"
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/metrics"
+	"djp.chapter42.de/a/internal/processor"
+	timebackoff "djp.chapter42.de/a/internal/time_backoff"
+	"djp.chapter42.de/a/internal/tmpl"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// Der Logger wird nur einmal gesetzt, da Goroutinen der Tests ihn auch nach
+// deren Ende noch verwenden können.
+func TestMain(m *testing.M) {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	logger.Log, _ = cfg.Build()
+	os.Exit(m.Run())
+}
+
+func TestProcessJobWithFakeClock(t *testing.T) {
+	// Die ersten beiden Prüfungen melden "nicht schreibbar", danach wird geschrieben
+	var checks, writes int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "clock-uid"}`))
+		case r.Method == http.MethodGet:
+			checks++
+			if checks <= 2 {
+				w.WriteHeader(http.StatusLocked)
+			}
+		case r.Method == http.MethodPut:
+			writes++
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "clock-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+	current.Random = clock.NewRandom(7)
+
+	job := data.PendingJob{ID: "clock-job", Job: data.Job{UID: "clock-uid", Data: "dmFsdWU="}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+
+	// Jede Wartezeit wird vorgespult statt abgewartet
+	var waits []time.Duration
+	for len(waits) < 3 {
+		fake.BlockUntil(1)
+		wait, _ := fake.NextWait()
+		waits = append(waits, wait)
+		fake.Advance(wait)
+	}
+	<-done
+
+	// Gleicher Seed: erste Phase des Targets, gleicher Jitter
+	expected := timebackoff.RestoreSinusBackoff(0, clock.NewRandom(7).Float64()*timebackoff.JitterFactor)
+	for attempt, wait := range waits {
+		assert.Equal(t, expected.CalculateBackoff(attempt), wait)
+	}
+	assert.Equal(t, 3, checks)
+	assert.Equal(t, 1, writes)
+	assert.Empty(t, pending)
+}
+
+func TestRequeueAndCancelJob(t *testing.T) {
+	var checks int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "cancel-uid"}`))
+		case r.Method == http.MethodGet:
+			checks++
+			w.WriteHeader(http.StatusLocked)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "cancel-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	job := data.PendingJob{ID: "cancel-job", Job: data.Job{UID: "cancel-uid", Data: "dmFsdWU="}}
+	successor := data.PendingJob{ID: "cancel-successor", Job: data.Job{UID: "cancel-uid", Data: "dmFsdWU="}, After: job.ID}
+	pending := []data.PendingJob{job, successor}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+
+	// Requeue bricht den Backoff ab und versucht sofort, ohne die Uhr vorzuspulen
+	fake.BlockUntil(1)
+	pendingMutex.Lock()
+	_, retrying, ok := processor.RequeueJob(job.ID, &pending)
+	pendingMutex.Unlock()
+	assert.True(t, ok)
+	assert.True(t, retrying)
+
+	fake.BlockUntil(2)
+	mu.Lock()
+	assert.Equal(t, 1, checks)
+	mu.Unlock()
+
+	// Abbruch beendet die Bearbeitung und gibt den Nachfolger frei
+	pendingMutex.Lock()
+	_, ok = processor.CancelJob(job.ID, &pending)
+	pendingMutex.Unlock()
+	assert.True(t, ok)
+	<-done
+
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, successor.ID, pending[0].ID)
+	assert.Empty(t, pending[0].After)
+	processor.CancelJob(successor.ID, &pending)
+}
+
+func TestRequeueQueuedJob(t *testing.T) {
+	current := &data.CurrentConfig{Name: "requeue-target"}
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	// Wiederhergestellter Job, der noch mit seinem alten Stand in der Queue steht
+	job := data.PendingJob{ID: "requeue-job", Job: data.Job{UID: "requeue-uid", Data: "dmFsdWU="}, Attempts: 5, PhaseShift: 1, Jitter: 0.1}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	pendingMutex.Lock()
+	_, active, ok := processor.RequeueJob(job.ID, &pending)
+	pendingMutex.Unlock()
+	assert.True(t, ok)
+	assert.False(t, active)
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+
+	// Der Worker beginnt mit dem zurückgesetzten Stand statt dem der Queue
+	fake.BlockUntil(1)
+	wait, _ := fake.NextWait()
+	assert.Equal(t, timebackoff.RestoreSinusBackoff(1, 0.1).CalculateBackoff(0), wait)
+	pendingMutex.Lock()
+	assert.Equal(t, 0, pending[0].Attempts)
+	processor.CancelJob(job.ID, &pending)
+	pendingMutex.Unlock()
+	<-done
+}
+
+func TestPipeline(t *testing.T) {
+	// fetch → write → unlock: beim ersten Versuch ist das Objekt noch gesperrt
+	var fetches, unlocks int
+	var written []byte
+	var ifMatch string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			fetches++
+			state := "ready"
+			if fetches == 1 {
+				state = "locked"
+			}
+			w.Header().Set("ETag", fmt.Sprintf(`"%d"`, fetches))
+			fmt.Fprintf(w, `{"meta": {"state": %q, "revisions": [{"id": "r%d"}]}}`, state, fetches)
+		case http.MethodPut:
+			ifMatch = r.Header.Get("If-Match")
+			written, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			unlocks++
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:    "pipeline-target",
+		BaseURL: server.URL,
+		Pipeline: []data.StepConfig{
+			{
+				Name:     "fetch",
+				Endpoint: "/objects/{{.UID}}",
+				Extract:  map[string]string{"etag": "header.etag", "state": "body.meta.state", "rev": "body.meta.revisions.0.id"},
+				Success:  data.SuccessConfig{Condition: `{{eq .Steps.fetch.state "ready"}}`},
+			},
+			{
+				Name:     "write",
+				Method:   "put",
+				Endpoint: "/objects/{{.UID}}",
+				Headers:  map[string]string{"if-match": "{{.Steps.fetch.etag}}"},
+				Body:     `{"rev": {{json .Steps.fetch.rev}}, "value": {{json .Payload}}}`,
+			},
+			{
+				Name:     "unlock",
+				Method:   "DELETE",
+				Endpoint: "/objects/{{.UID}}/lock",
+				Success:  data.SuccessConfig{Status: []int{http.StatusNoContent, http.StatusNotFound}},
+				Always:   true,
+			},
+		},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	job := data.PendingJob{ID: "pipeline-job", Job: data.Job{UID: "pipeline-uid", Data: "dmFsdWU=", ContentType: "json"}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	for range 2 {
+		fake.BlockUntil(1)
+		wait, _ := fake.NextWait()
+		fake.Advance(wait)
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, fetches)
+	assert.Equal(t, 2, unlocks) // auch nach dem gescheiterten ersten Versuch
+	assert.Equal(t, `"2"`, ifMatch)
+	assert.JSONEq(t, `{"rev": "r2", "value": "value"}`, string(written))
+	assert.Empty(t, pending)
+}
+
+func TestPipelineTransform(t *testing.T) {
+	var written []byte
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"revision": "r7"}`))
+		case http.MethodPut:
+			written, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	// Die Transformation wird erst beim Schreiben gerendert und sieht die Ausgaben
+	// bisheriger Schritte; dort ist .Payload der noch nicht transformierte Payload
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "transform-pipeline-target",
+		BaseURL:   server.URL,
+		Transform: `{"rev": {{json .Steps.fetch.rev}}, "attempt": {{.Meta.Attempts}}, "data": {{.Payload}}}`,
+		Pipeline: []data.StepConfig{
+			{Name: "fetch", Endpoint: "/objects/{{.UID}}", Extract: map[string]string{"rev": "body.revision"}},
+			{Name: "write", Method: "PUT", Endpoint: "/objects/{{.UID}}", Body: "{{.Payload}}"},
+		},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	job := data.PendingJob{ID: "transform-pipeline-job", Job: data.Job{UID: "transform-uid", Data: "eyJhIjogMX0=", ContentType: "json"}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	wait, _ := fake.NextWait()
+	fake.Advance(wait)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.JSONEq(t, `{"rev": "r7", "attempt": 0, "data": {"a": 1}}`, string(written))
+	assert.Empty(t, pending)
+}
+
+func TestVerifyWrite(t *testing.T) {
+	// Das Target bestätigt den ersten Schreibvorgang, verwirft ihn aber
+	var writes, reads int
+	stored := `{"value": 0}`
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "verify-uid"}`))
+		case strings.HasPrefix(r.URL.Path, "/stored/"):
+			reads++
+			w.Write([]byte(stored))
+		case r.Method == http.MethodPut:
+			writes++
+			if writes > 1 {
+				body, _ := io.ReadAll(r.Body)
+				stored = string(body)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "verify-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+		Verify: data.VerifyConfig{
+			Mode:     data.VerifyField,
+			Endpoint: "/stored/{{.UID}}",
+			Field:    "body.value",
+			Attempts: 2,
+			Interval: time.Second,
+		},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	mismatches := metrics.Value("wavely_verify_total", "target", "verify-target", "result", "mismatch")
+	confirmed := metrics.Value("wavely_verify_total", "target", "verify-target", "result", "ok")
+
+	job := data.PendingJob{ID: "verify-job", Job: data.Job{UID: "verify-uid", Data: "eyJ2YWx1ZSI6IDQyfQ=="}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	// Backoff, Abstand der Lesevorgänge, Backoff
+	for range 3 {
+		fake.BlockUntil(1)
+		wait, _ := fake.NextWait()
+		fake.Advance(wait)
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, writes)
+	assert.Equal(t, 3, reads)
+	assert.Equal(t, mismatches+1, metrics.Value("wavely_verify_total", "target", "verify-target", "result", "mismatch"))
+	assert.Equal(t, confirmed+1, metrics.Value("wavely_verify_total", "target", "verify-target", "result", "ok"))
+	assert.Empty(t, pending)
+}
+
+func TestLockLease(t *testing.T) {
+	processor.LeaseFile = filepath.Join(t.TempDir(), "leases.json")
+	defer func() { processor.LeaseFile = "" }()
+
+	var acquires, renews int
+	var released []string
+	var writeToken string
+	writing, renewed, proceed := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/objects/") {
+			// Langer Schreibvorgang, währenddessen wird die Lease verlängert
+			mu.Lock()
+			writeToken = r.Header.Get("X-Lock-Token")
+			mu.Unlock()
+			close(writing)
+			<-proceed
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "lock-uid"}`))
+		case r.Method == http.MethodPost:
+			// Beim ersten Versuch hält ein anderer Client die Sperre
+			acquires++
+			if acquires == 1 {
+				w.WriteHeader(http.StatusLocked)
+				return
+			}
+			w.Write([]byte(`{"lease": {"token": "token-2"}}`))
+		case r.Method == http.MethodPut:
+			renews++
+			close(renewed)
+		case r.Method == http.MethodDelete:
+			released = append(released, strings.TrimPrefix(r.URL.Path, "/locks/lock-uid/"))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "lock-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+		Lock: data.LockConfig{
+			Acquire: data.RequestConfig{Endpoint: "/locks/{{.UID}}"},
+			Renew:   data.RequestConfig{Endpoint: "/locks/{{.UID}}/{{.Lock.Token}}"},
+			Release: data.RequestConfig{Endpoint: "/locks/{{.UID}}/{{.Lock.Token}}", Status: []int{http.StatusOK, http.StatusNotFound}},
+			Token:   "body.lease.token",
+			Header:  "X-Lock-Token",
+			Lease:   10 * time.Second,
+		},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	job := data.PendingJob{ID: "lock-job", Job: data.Job{UID: "lock-uid", Data: "dmFsdWU="}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	for range 2 {
+		fake.BlockUntil(1)
+		wait, _ := fake.NextWait()
+		fake.Advance(wait)
+	}
+
+	// Während des Schreibens steht die Sperre im Journal und wird verlängert
+	<-writing
+	fake.BlockUntil(1)
+	fake.Advance(5 * time.Second)
+	<-renewed
+	journal, err := os.ReadFile(processor.LeaseFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(journal), "token-2")
+	close(proceed)
+	<-done
+
+	mu.Lock()
+	assert.Equal(t, 2, acquires)
+	assert.Equal(t, 1, renews)
+	assert.Equal(t, "token-2", writeToken)
+	assert.Equal(t, []string{"token-2"}, released)
+	mu.Unlock()
+	assert.NoFileExists(t, processor.LeaseFile)
+	assert.Empty(t, pending)
+
+	// Nach einem Absturz verbliebene Sperren werden beim Start freigegeben
+	stale := `[{"job_id": "crashed", "target": "lock-target", "uid": "lock-uid", "token": "stale-token"}]`
+	assert.NoError(t, os.WriteFile(processor.LeaseFile, []byte(stale), 0644))
+	processor.RecoverLocks(current)
+	mu.Lock()
+	assert.Equal(t, []string{"token-2", "stale-token"}, released)
+	mu.Unlock()
+	assert.NoFileExists(t, processor.LeaseFile)
+}
+
+func TestWriteConvertsPayload(t *testing.T) {
+	var written, contentType string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "convert-uid"}`))
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			written, contentType = string(body), r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:        "convert-target",
+		BaseURL:     server.URL,
+		Endpoints:   data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+		ContentType: convert.XML,
+		Convert:     convert.Options{XML: convert.XMLOptions{Root: "object"}},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	// {"value": 42}
+	job := data.PendingJob{ID: "convert-job", Job: data.Job{UID: "convert-uid", Data: "eyJ2YWx1ZSI6IDQyfQ==", ContentType: convert.JSON}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	wait, _ := fake.NextWait()
+	fake.Advance(wait)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<object><value>42</value></object>`, written)
+	assert.Equal(t, "application/xml", contentType)
+	assert.Empty(t, pending)
+}
+
+func TestCancelDuringBlackout(t *testing.T) {
+	calendar, err := blackout.BuildCalendar(blackout.Config{
+		Timezone: "UTC",
+		Windows:  []blackout.WindowConfig{{Start: "06:00", End: "18:00"}},
+	})
+	assert.NoError(t, err)
+	current := &data.CurrentConfig{Name: "blackout-target", Calendar: calendar}
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	// Erster Versuch zum geplanten Zeitpunkt, der in die Sperrzeit fällt
+	job := data.PendingJob{ID: "blackout-job", Job: data.Job{UID: "blackout-uid", Data: "dmFsdWU=", RunAt: fake.Now()}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+
+	// Der Abbruch weckt den Job, ohne die Uhr vorzuspulen
+	fake.BlockUntil(1)
+	pendingMutex.Lock()
+	_, ok := processor.CancelJob(job.ID, &pending)
+	pendingMutex.Unlock()
+	assert.True(t, ok)
+	<-done
+	assert.Empty(t, pending)
+}
+
+func TestCancelDuringOpenBreaker(t *testing.T) {
+	current := &data.CurrentConfig{Name: "breaker-target", Breaker: breaker.Build("breaker-target", breaker.Config{ConsecutiveFailures: 1})}
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+	current.Breaker.Record(true)
+
+	job := data.PendingJob{ID: "breaker-job", Job: data.Job{UID: "breaker-uid", Data: "dmFsdWU=", RunAt: fake.Now()}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+
+	// Der geparkte Job endet mit dem Abbruch, ohne dass der Breaker schließt
+	fake.BlockUntil(1)
+	pendingMutex.Lock()
+	_, ok := processor.CancelJob(job.ID, &pending)
+	pendingMutex.Unlock()
+	assert.True(t, ok)
+	<-done
+	assert.Empty(t, pending)
+	assert.Equal(t, breaker.StateOpen, current.Breaker.State())
+}
+
+func TestWriteKeepsUID(t *testing.T) {
+	// Der erste Schreibversuch scheitert, jeder Versuch liefert eine neue Revision
+	var paths []string
+	var revisions, writes int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			revisions++
+			fmt.Fprintf(w, `{"latest_revision": "rev-%d"}`, revisions)
+		case r.Method == http.MethodPut:
+			writes++
+			if writes == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "revision-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}/writable", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	job := data.PendingJob{ID: "revision-job", Job: data.Job{UID: "doc-uid", Data: "dmFsdWU=", RunAt: fake.Now()}}
+	pending := []data.PendingJob{job}
+	var pendingMutex sync.Mutex
+
+	done := make(chan struct{})
+	go func() {
+		processor.ProcessJob(job, &pending, &pendingMutex, current)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	wait, _ := fake.NextWait()
+	fake.Advance(wait)
+	<-done
+
+	// Jeder Versuch fragt die Revision zur UID ab, geprüft und geschrieben
+	// wird an der jeweiligen Revision
+	assert.Equal(t, []string{
+		"GET /revision/doc-uid", "GET /objects/rev-1/writable", "PUT /objects/rev-1",
+		"GET /revision/doc-uid", "GET /objects/rev-2/writable", "PUT /objects/rev-2",
+	}, paths)
+	assert.Empty(t, pending)
+}
+
+// Fährt die Verarbeitung herunter und steht daher am Ende der Datei.
+func TestShutdownDrainsAndAbortsInFlightWrites(t *testing.T) {
+	var writing sync.WaitGroup
+	writing.Add(2)
+	release := make(chan struct{})
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/revision/"):
+			w.Write([]byte(`{"latest_revision": "` + strings.TrimPrefix(r.URL.Path, "/revision/") + `"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/objects/stuck-uid":
+			// Antwortet nie; erst nach dem Lesen des Bodys bemerkt der Server den Abbruch
+			io.ReadAll(r.Body)
+			writing.Done()
+			<-r.Context().Done()
+			close(aborted)
+		case r.Method == http.MethodPut:
+			writing.Done()
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.WavelyConfig{Current: data.CurrentConfig{
+		Name:      "shutdown-target",
+		BaseURL:   server.URL,
+		Endpoints: data.EndpointConfig{Check: "/objects/{{.UID}}", Revision: "/revision/{{.UID}}", Write: "/objects/{{.UID}}"},
+	}}
+	assert.NoError(t, tmpl.PrepareTemplates(cfg))
+	current := &cfg.Current
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	config.SetClock(current, fake)
+
+	// Zwei Jobs schreiben sofort, einer davon hängt, der dritte wartet im Backoff
+	writer := data.PendingJob{ID: "writer", Job: data.Job{UID: "writer-uid", Data: "dmFsdWU=", RunAt: fake.Now()}}
+	stuck := data.PendingJob{ID: "stuck", Job: data.Job{UID: "stuck-uid", Data: "dmFsdWU=", RunAt: fake.Now()}}
+	sleeper := data.PendingJob{ID: "sleeper", Job: data.Job{UID: "sleeper-uid", Data: "dmFsdWU="}}
+	pending := []data.PendingJob{writer, stuck, sleeper}
+	var pendingMutex sync.Mutex
+
+	var workers sync.WaitGroup
+	for _, job := range []data.PendingJob{writer, stuck, sleeper} {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			processor.ProcessJob(job, &pending, &pendingMutex, current)
+		}()
+	}
+	writing.Wait()
+	fake.BlockUntil(1)
+
+	drained := make(chan bool)
+	go func() { drained <- processor.Shutdown(200 * time.Millisecond) }()
+
+	// Die laufenden Schreibvorgänge halten das Herunterfahren auf, einer
+	// wird innerhalb der Frist fertig
+	select {
+	case <-drained:
+		t.Fatal("Shutdown wartet nicht auf die laufenden Schreibvorgänge")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.False(t, <-drained)
+
+	// Nach Ablauf der Frist wird der hängende Aufruf abgebrochen
+	assert.True(t, processor.Abort())
+	<-aborted
+	workers.Wait()
+	assert.Zero(t, processor.InFlight())
+
+	// Geschriebener Job ist verbucht, die übrigen bleiben für die Persistierung
+	assert.True(t, processor.Stopped())
+	var ids []string
+	for _, j := range pending {
+		ids = append(ids, j.ID)
+	}
+	assert.ElementsMatch(t, []string{"stuck", "sleeper"}, ids)
+}
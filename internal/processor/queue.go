@@ -0,0 +1,300 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultQueueCapacity int = 100
+	DefaultRetryAfter        = 5 * time.Second
+	MaxRetryAfter            = 5 * time.Minute
+
+	// Anzahl der Abschlüsse, aus denen der aktuelle Durchsatz geschätzt wird
+	throughputSamples = 50
+	spillFeedInterval = 200 * time.Millisecond
+)
+
+var ErrQueueFull = errors.New("queue ist voll")
+
+var JobQueue = NewScheduler(DefaultQueueCapacity, DefaultWeights)
+
+var (
+	spill      *spillQueue
+	throughput = &completionTracker{}
+)
+
+// InitQueue übernimmt Kapazität und Gewichte des Targets für die Queue und
+// startet ggf. die Auslagerung auf die Festplatte.
+func InitQueue(target string, cfg data.QueueConfig) {
+	JobQueue.Configure(cfg.Capacity, cfg.Weights)
+
+	if cfg.SpillFile != "" {
+		spill = &spillQueue{path: cfg.SpillFile, limit: cfg.SpillLimit}
+		go spill.feed()
+		logger.Log.Info("Auslagerung der Queue aktiviert:", zap.String("target", target), zap.String("file", cfg.SpillFile))
+	}
+}
+
+// Submit übergibt einen Job an die Worker, ohne zu blockieren. Ist die Queue voll,
+// wird der Job ausgelagert (falls konfiguriert). Mit force wird der Job auch bei
+// voller Queue über die Kapazität hinaus angenommen.
+func Submit(job data.PendingJob, force bool) error {
+	// Geplante Jobs reservieren ihren Platz in der Queue bis zur Fälligkeit. Sie
+	// werden nicht ausgelagert, da die Auslagerung sie vorzeitig einreihen würde.
+	if job.Job.DueAt().After(Delayed.now()) {
+		if Delayed.Add(job, force) {
+			return nil
+		}
+		return ErrQueueFull
+	}
+
+	if JobQueue.TryPush(job) {
+		return nil
+	}
+
+	if spill != nil {
+		if err := spill.push(job); err == nil {
+			return nil
+		} else if !errors.Is(err, ErrQueueFull) {
+			logger.Log.Error("Fehler beim Auslagern des Jobs:", zap.String("id", job.ID), zap.Error(err))
+		}
+	}
+
+	if force {
+		JobQueue.ForcePush(job)
+		return nil
+	}
+	return ErrQueueFull
+}
+
+// FreeSlots liefert die Anzahl der aktuell freien Plätze in Queue und Auslagerung.
+func FreeSlots() int {
+	free := JobQueue.Free()
+	if spill != nil {
+		free += spill.free()
+	}
+	return free
+}
+
+// RetryAfter schätzt anhand des aktuellen Durchsatzes, wann wieder Platz in der
+// Queue sein wird.
+func RetryAfter() time.Duration {
+	rate := throughput.rate()
+	if rate <= 0 {
+		return DefaultRetryAfter
+	}
+
+	queued := JobQueue.Len()
+	if spill != nil {
+		queued += spill.len()
+	}
+
+	wait := time.Duration(math.Ceil(float64(queued)/rate)) * time.Second
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if wait > MaxRetryAfter {
+		wait = MaxRetryAfter
+	}
+	return wait
+}
+
+// RecoverSpill übernimmt nach einem Absturz ausgelagerte Jobs, die nicht bereits
+// aus der Persistierung wiederhergestellt wurden.
+func RecoverSpill(pendingJobs *[]data.PendingJob, jobMutex *sync.Mutex) {
+	if spill == nil {
+		return
+	}
+
+	jobs, err := spill.load()
+	if err != nil {
+		logger.Log.Error("Fehler beim Lesen der ausgelagerten Jobs:", zap.String("file", spill.path), zap.Error(err))
+		return
+	}
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	spill.mu.Lock()
+	defer spill.mu.Unlock()
+
+	known := make(map[string]bool, len(*pendingJobs)+len(spill.jobs))
+	for _, j := range *pendingJobs {
+		known[j.ID] = true
+	}
+	for _, j := range spill.jobs {
+		known[j.ID] = true
+	}
+
+	recovered := 0
+	for _, job := range jobs {
+		if known[job.ID] {
+			continue
+		}
+		*pendingJobs = append(*pendingJobs, job)
+		spill.jobs = append(spill.jobs, job)
+		recovered++
+	}
+	if recovered > 0 {
+		logger.Log.Info("Ausgelagerte Jobs wiederhergestellt:", zap.String("file", spill.path), zap.Int("count", recovered))
+	}
+}
+
+// spillQueue nimmt Jobs auf, wenn die Queue voll ist. Jeder Job wird sofort in
+// die Datei geschrieben, damit er auch einen Absturz übersteht.
+type spillQueue struct {
+	mu    sync.Mutex
+	path  string
+	limit int
+	jobs  []data.PendingJob
+}
+
+func (s *spillQueue) push(job data.PendingJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit > 0 && len(s.jobs) >= s.limit {
+		return ErrQueueFull
+	}
+
+	line, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// feed reicht ausgelagerte Jobs in Reihenfolge an die Queue weiter, sobald dort
+// Platz frei wird.
+func (s *spillQueue) feed() {
+	ticker := time.NewTicker(spillFeedInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.feedQueue()
+	}
+}
+
+// feedQueue reicht so viele Jobs weiter, wie in die Queue passen, und schreibt
+// die übrigen zurück in die Datei. Weitergereichte Jobs werden so nach einem
+// Absturz nicht erneut übernommen.
+func (s *spillQueue) feedQueue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fed := 0
+	for len(s.jobs) > 0 && JobQueue.TryPush(s.jobs[0]) {
+		s.jobs = s.jobs[1:]
+		fed++
+	}
+	if fed == 0 {
+		return
+	}
+	if err := s.rewrite(); err != nil {
+		logger.Log.Warn("Fehler beim Aktualisieren der Auslagerungsdatei:", zap.String("file", s.path), zap.Error(err))
+	}
+}
+
+// rewrite ersetzt den Inhalt der Datei durch die noch ausgelagerten Jobs.
+// Aufruf nur mit s.mu.
+func (s *spillQueue) rewrite() error {
+	var buf bytes.Buffer
+	for _, job := range s.jobs {
+		line, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return os.WriteFile(s.path, buf.Bytes(), 0644)
+}
+
+func (s *spillQueue) load() ([]data.PendingJob, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var jobs []data.PendingJob
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var job data.PendingJob
+		if err := json.Unmarshal(scanner.Bytes(), &job); err != nil {
+			logger.Log.Warn("Ungültiger Eintrag in der Auslagerungsdatei:", zap.Error(err))
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, scanner.Err()
+}
+
+func (s *spillQueue) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.jobs)
+}
+
+func (s *spillQueue) free() int {
+	if s.limit <= 0 {
+		return math.MaxInt32
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit - len(s.jobs)
+}
+
+// completionTracker merkt sich die Zeitpunkte der letzten abgeschlossenen Jobs.
+type completionTracker struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (t *completionTracker) record() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.times = append(t.times, time.Now())
+	if len(t.times) > throughputSamples {
+		t.times = t.times[len(t.times)-throughputSamples:]
+	}
+}
+
+// rate liefert die abgeschlossenen Jobs pro Sekunde.
+func (t *completionTracker) rate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.times) < 2 {
+		return 0
+	}
+	elapsed := time.Since(t.times[0]).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(len(t.times)) / elapsed
+}
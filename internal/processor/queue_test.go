@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"djp.chapter42.de/a/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverSpill(t *testing.T) {
+	queue := JobQueue
+	JobQueue = NewScheduler(1, nil)
+	defer func() { JobQueue, spill = queue, nil }()
+
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	spill = &spillQueue{path: path}
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, spill.push(data.PendingJob{ID: id}))
+	}
+
+	// Weitergereichte Jobs verschwinden sofort aus der Datei
+	spill.feedQueue()
+	assert.Equal(t, "a", JobQueue.Pop().ID)
+	jobs, err := spill.load()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	// Neustart nach einem Absturz: b ist bereits aus der Persistierung bekannt
+	spill = &spillQueue{path: path}
+	pending := []data.PendingJob{{ID: "b"}}
+	var pendingMutex sync.Mutex
+	RecoverSpill(&pending, &pendingMutex)
+	RecoverSpill(&pending, &pendingMutex)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "c", pending[1].ID)
+	assert.Equal(t, 1, spill.len())
+
+	spill.feedQueue()
+	assert.Equal(t, "c", JobQueue.Pop().ID)
+	jobs, err = spill.load()
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
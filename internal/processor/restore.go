@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"sort"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ScheduleRestored übergibt wiederhergestellte Jobs, die ältesten zuerst, an die
+// Worker. Mit rate > 0 werden höchstens rate Jobs pro Sekunde eingespeist, damit
+// ein Neustart das Target nicht mit einem Schlag trifft.
+func ScheduleRestored(jobs []data.PendingJob, rate float64) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	go func() {
+		clk := clock.Or(Delayed.clock)
+		var interval time.Duration
+		if rate > 0 {
+			interval = time.Duration(float64(time.Second) / rate)
+		}
+
+		pushed := 0
+		for _, job := range jobs {
+			// Noch nicht fällige Jobs warten im Heap auf ihren Zeitpunkt. Sie waren
+			// bereits angenommen und belegen ihren Platz daher in jedem Fall.
+			if job.Job.DueAt().After(Delayed.now()) {
+				Delayed.Add(job, true)
+				continue
+			}
+			if interval > 0 && pushed > 0 {
+				clk.Sleep(interval)
+			}
+			JobQueue.PushWait(job)
+			pushed++
+		}
+		logger.Log.Info("Wiederhergestellte Jobs eingeplant:", zap.Int("count", len(jobs)))
+	}()
+}
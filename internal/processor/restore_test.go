@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleRestored(t *testing.T) {
+	queue, delayed := JobQueue, Delayed
+	defer func() { JobQueue, Delayed = queue, delayed }()
+
+	start := time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	JobQueue = NewScheduler(10, nil)
+	Delayed = newDelayQueue()
+	Delayed.clock = fake
+
+	job := func(id string, created time.Duration) data.PendingJob {
+		return data.PendingJob{ID: id, CreatedAt: start.Add(-created)}
+	}
+	later := job("later", time.Hour)
+	later.Job.NotBefore = start.Add(time.Minute)
+
+	// Zwei Jobs pro Sekunde, die ältesten zuerst; der noch nicht fällige Job
+	// kommt ohne Takt in den Heap
+	ScheduleRestored([]data.PendingJob{job("new", time.Second), later, job("old", 3*time.Second), job("mid", 2*time.Second)}, 2)
+
+	assert.Equal(t, "old", JobQueue.Pop().ID)
+	for _, id := range []string{"mid", "new"} {
+		fake.BlockUntil(1)
+		assert.Equal(t, 0, JobQueue.Len())
+		wait, _ := fake.NextWait()
+		assert.Equal(t, 500*time.Millisecond, wait)
+		fake.Advance(wait)
+		assert.Equal(t, id, JobQueue.Pop().ID)
+	}
+	assert.Equal(t, 1, Delayed.Len())
+}
@@ -0,0 +1,254 @@
+package processor
+
+import (
+	"strings"
+	"sync"
+
+	"djp.chapter42.de/a/internal/data"
+)
+
+// Reihenfolge, in der die Prioritätsklassen bedient werden
+var priorityOrder = []string{data.PriorityHigh, data.PriorityNormal, data.PriorityLow}
+
+// DefaultWeights legt fest, wie viele Jobs eine Klasse pro Runde erhält. Jede
+// Klasse bekommt mindestens einen Platz, dadurch verhungern niedrige Prioritäten nicht.
+var DefaultWeights = map[string]int{
+	data.PriorityHigh:   6,
+	data.PriorityNormal: 3,
+	data.PriorityLow:    1,
+}
+
+// Scheduler ersetzt die FIFO-Queue: Prioritätsklassen werden per Weighted Round
+// Robin bedient, innerhalb einer Klasse reihum je Target und Client, sodass ein
+// einzelner Einsender die Worker nicht blockieren kann.
+type Scheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	capacity int
+	size     int
+	reserved int
+	classes  map[string]*priorityClass
+	cursor   int
+	credit   int
+}
+
+type priorityClass struct {
+	weight int
+	flows  []*flow
+	index  map[string]*flow
+	next   int
+	size   int
+}
+
+type flow struct {
+	key  string
+	jobs []data.PendingJob
+}
+
+func NewScheduler(capacity int, weights map[string]int) *Scheduler {
+	s := &Scheduler{classes: make(map[string]*priorityClass)}
+	s.cond = sync.NewCond(&s.mu)
+	for _, name := range priorityOrder {
+		s.classes[name] = &priorityClass{index: make(map[string]*flow)}
+	}
+	s.Configure(capacity, weights)
+	return s
+}
+
+// Configure setzt Kapazität und Gewichte, ohne wartende Jobs zu verlieren.
+func (s *Scheduler) Configure(capacity int, weights map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if capacity <= 0 {
+		capacity = DefaultQueueCapacity
+	}
+	s.capacity = capacity
+
+	for _, name := range priorityOrder {
+		weight := weights[name]
+		if weight <= 0 {
+			weight = DefaultWeights[name]
+		}
+		s.classes[name].weight = weight
+	}
+	s.credit = s.classes[priorityOrder[s.cursor]].weight
+	s.cond.Broadcast()
+}
+
+// TryPush reiht einen Job ein, sofern Platz ist.
+func (s *Scheduler) TryPush(job data.PendingJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+s.reserved >= s.capacity {
+		return false
+	}
+	s.push(job)
+	return true
+}
+
+// PushWait wartet, bis Platz ist, und reiht den Job dann ein.
+func (s *Scheduler) PushWait(job data.PendingJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.size+s.reserved >= s.capacity {
+		s.cond.Wait()
+	}
+	s.push(job)
+}
+
+// ForcePush reiht einen Job unabhängig von der Kapazität ein.
+func (s *Scheduler) ForcePush(job data.PendingJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.push(job)
+}
+
+// reserve hält einen Platz für einen geplanten Job frei, bis er mit
+// pushReserved eingereiht wird. Mit force auch über die Kapazität hinaus.
+func (s *Scheduler) reserve(force bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force && s.size+s.reserved >= s.capacity {
+		return false
+	}
+	s.reserved++
+	return true
+}
+
+// pushReserved reiht einen Job auf dem zuvor reservierten Platz ein.
+func (s *Scheduler) pushReserved(job data.PendingJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reserved > 0 {
+		s.reserved--
+	}
+	s.push(job)
+}
+
+// unreserve gibt einen reservierten Platz frei, etwa wenn ein geplanter Job vor
+// seiner Fälligkeit abgebrochen wird.
+func (s *Scheduler) unreserve() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reserved > 0 {
+		s.reserved--
+	}
+	s.cond.Broadcast()
+}
+
+// Pop wartet auf den nächsten Job gemäß Priorität und Fairness.
+func (s *Scheduler) Pop() data.PendingJob {
+	job, _ := s.PopUntil(nil)
+	return job
+}
+
+// PopUntil wartet wie Pop, liefert aber false, sobald quit geschlossen ist. Wer
+// quit schließt, weckt wartende Aufrufer mit Wake.
+func (s *Scheduler) PopUntil(quit <-chan struct{}) (data.PendingJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		select {
+		case <-quit:
+			return data.PendingJob{}, false
+		default:
+		}
+		if s.size > 0 {
+			break
+		}
+		s.cond.Wait()
+	}
+
+	for {
+		class := s.classes[priorityOrder[s.cursor]]
+		if class.size > 0 && s.credit > 0 {
+			s.credit--
+			s.size--
+			s.cond.Broadcast()
+			return class.pop(), true
+		}
+		s.cursor = (s.cursor + 1) % len(priorityOrder)
+		s.credit = s.classes[priorityOrder[s.cursor]].weight
+	}
+}
+
+// Wake weckt alle wartenden Aufrufer, damit sie ihr quit prüfen.
+func (s *Scheduler) Wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Free liefert die Anzahl der Plätze, die weder belegt noch reserviert sind.
+func (s *Scheduler) Free() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return max(s.capacity-s.size-s.reserved, 0)
+}
+
+func (s *Scheduler) Cap() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capacity
+}
+
+func (s *Scheduler) push(job data.PendingJob) {
+	s.classes[NormalizePriority(job.Job.Priority)].push(job)
+	s.size++
+	s.cond.Broadcast()
+}
+
+func (c *priorityClass) push(job data.PendingJob) {
+	key := job.Target + "/" + job.Client
+	f, ok := c.index[key]
+	if !ok {
+		f = &flow{key: key}
+		c.index[key] = f
+		c.flows = append(c.flows, f)
+	}
+	f.jobs = append(f.jobs, job)
+	c.size++
+}
+
+// pop bedient die Flows der Klasse reihum.
+func (c *priorityClass) pop() data.PendingJob {
+	if c.next >= len(c.flows) {
+		c.next = 0
+	}
+	f := c.flows[c.next]
+	job := f.jobs[0]
+	f.jobs = f.jobs[1:]
+	c.size--
+
+	if len(f.jobs) == 0 {
+		delete(c.index, f.key)
+		c.flows = append(c.flows[:c.next], c.flows[c.next+1:]...)
+	} else {
+		c.next++
+	}
+	return job
+}
+
+// NormalizePriority bildet leere oder unbekannte Prioritäten auf "normal" ab.
+func NormalizePriority(priority string) string {
+	switch p := strings.ToLower(priority); p {
+	case data.PriorityHigh, data.PriorityLow:
+		return p
+	default:
+		return data.PriorityNormal
+	}
+}
@@ -0,0 +1,59 @@
+package processor_test
+
+import (
+	"fmt"
+	"testing"
+
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerFairness(t *testing.T) {
+	scheduler := processor.NewScheduler(100, nil)
+
+	push := func(client, priority string, n int) {
+		for i := 0; i < n; i++ {
+			scheduler.ForcePush(data.PendingJob{ID: fmt.Sprintf("%s-%s-%d", client, priority, i), Client: client, Job: data.Job{Priority: priority}})
+		}
+	}
+	push("noisy", data.PriorityLow, 10)
+	push("noisy", data.PriorityNormal, 10)
+	push("quiet", data.PriorityNormal, 2)
+	push("quiet", data.PriorityHigh, 1)
+
+	var order []string
+	for scheduler.Len() > 0 {
+		order = append(order, scheduler.Pop().ID)
+	}
+
+	// Hohe Priorität zuerst, danach wechseln sich die Clients ab
+	assert.Equal(t, "quiet-high-0", order[0])
+	assert.Equal(t, []string{"noisy-normal-0", "quiet-normal-0", "noisy-normal-1"}, order[1:4])
+	// Niedrige Priorität verhungert nicht
+	assert.Equal(t, "noisy-low-0", order[4])
+	assert.Len(t, order, 23)
+}
+
+func TestSchedulerPopUntil(t *testing.T) {
+	scheduler := processor.NewScheduler(10, nil)
+	quit := make(chan struct{})
+
+	// Ein wartender Aufrufer gibt auf, sobald quit geschlossen und er geweckt wird
+	result := make(chan bool)
+	go func() {
+		_, ok := scheduler.PopUntil(quit)
+		result <- ok
+	}()
+	close(quit)
+	scheduler.Wake()
+	assert.False(t, <-result)
+
+	// Auch wartende Jobs werden nach quit nicht mehr übernommen
+	scheduler.ForcePush(data.PendingJob{ID: "queued"})
+	_, ok := scheduler.PopUntil(quit)
+	assert.False(t, ok)
+	job, ok := scheduler.PopUntil(nil)
+	assert.True(t, ok)
+	assert.Equal(t, "queued", job.ID)
+}
@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"djp.chapter42.de/a/internal/control"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Laufende Versuche gegen das Target. Beim Herunterfahren werden keine neuen
+// Versuche mehr begonnen und Backoff-Wartezeiten abgebrochen; begonnene
+// Versuche dürfen innerhalb der Frist zu Ende laufen.
+var (
+	lifecycleMu sync.Mutex
+	stopping    bool
+	inFlight    sync.WaitGroup
+	running     atomic.Int64
+
+	stopCtx, stop = context.WithCancel(context.Background())
+	// Endet erst mit Abort und bricht dann die Aufrufe laufender Versuche ab
+	abortCtx, abort = context.WithCancel(context.Background())
+)
+
+// beginAttempt meldet einen Versuch an. Liefert false, wenn bereits
+// heruntergefahren wird.
+func beginAttempt() bool {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+
+	if stopping {
+		return false
+	}
+	inFlight.Add(1)
+	running.Add(1)
+	return true
+}
+
+func endAttempt() {
+	running.Add(-1)
+	inFlight.Done()
+}
+
+// InFlight liefert die Anzahl der laufenden Versuche.
+func InFlight() int {
+	return int(running.Load())
+}
+
+// awaitControls parkt den Job, solange das Target pausiert, geleert wird oder
+// der Wartungsmodus aktiv ist. Liefert false, wenn dabei heruntergefahren wird.
+func awaitControls(target, uid string) bool {
+	for parked := false; ; parked = true {
+		allowed, changed := control.State.Check(target)
+		if allowed {
+			return true
+		}
+		if !parked {
+			logger.Log.Debug("Target angehalten, Job geparkt:", zap.String("target", target), zap.String("uid", uid))
+		}
+		select {
+		case <-changed:
+		case <-stopCtx.Done():
+			return false
+		}
+	}
+}
+
+// Stopped meldet, ob die Verarbeitung heruntergefahren wird.
+func Stopped() bool {
+	return stopCtx.Err() != nil
+}
+
+// Shutdown beendet die Verarbeitung: Es werden keine neuen Versuche begonnen,
+// wartende Jobs verbleiben in der Liste der ausstehenden Jobs und laufende
+// Schreibvorgänge erhalten bis zu grace Zeit, ihr Ergebnis zu verbuchen.
+// Liefert false, wenn die Frist abgelaufen ist.
+func Shutdown(grace time.Duration) bool {
+	lifecycleMu.Lock()
+	stopping = true
+	stop()
+	lifecycleMu.Unlock()
+
+	if !awaitInFlight(grace) {
+		logger.Log.Warn("Frist für laufende Schreibvorgänge abgelaufen:", zap.Duration("grace", grace))
+		return false
+	}
+	logger.Log.Info("Laufende Schreibvorgänge abgeschlossen.")
+	return true
+}
+
+// abortTimeout begrenzt das Warten auf abgebrochene Versuche.
+const abortTimeout = 5 * time.Second
+
+// Abort bricht nach abgelaufener Frist die Aufrufe der noch laufenden Versuche
+// ab und wartet auf deren Ende. Danach kommt kein Schreibvorgang mehr beim
+// Target an, Sperren können freigegeben und Jobs gesichert werden. Liefert
+// false, wenn Versuche auch dann nicht enden.
+func Abort() bool {
+	abort()
+	if !awaitInFlight(abortTimeout) {
+		logger.Log.Error("Laufende Versuche nach dem Abbruch nicht beendet:", zap.Int("in_flight", InFlight()))
+		return false
+	}
+	logger.Log.Info("Laufende Schreibvorgänge abgebrochen.")
+	return true
+}
+
+// awaitInFlight wartet bis zu grace, bis keine Versuche mehr laufen.
+func awaitInFlight(grace time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(grace):
+		return false
+	}
+}
@@ -0,0 +1,212 @@
+package processor
+
+import (
+	"cmp"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/convert"
+	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/external"
+	"djp.chapter42.de/a/internal/logger"
+	"djp.chapter42.de/a/internal/metrics"
+	"djp.chapter42.de/a/internal/tmpl"
+	"go.uber.org/zap"
+)
+
+func init() {
+	metrics.Describe("wavely_verify_total", "Post-write verifications per target and result (ok, mismatch).")
+}
+
+// errNotFound meldet eine noch nicht vorhandene Ressource.
+var errNotFound = errors.New("Ressource nicht gefunden")
+
+// revisionBefore liest im Modus revision den Stand vor dem Schreiben. Eine noch
+// nicht vorhandene Ressource hat keine Revision (nil).
+func revisionBefore(job *data.PendingJob, currentCfg *data.CurrentConfig) (any, error) {
+	resp, _, err := readBack(job, currentCfg)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return extract(resp, currentCfg.Verify.Field)
+}
+
+// verifyWrite liest die Ressource nach dem Schreiben bis zu verify.attempts-mal
+// erneut und vergleicht sie mit dem geschriebenen Payload. Stimmt sie danach
+// nicht überein, gilt der Schreibversuch als gescheitert.
+func verifyWrite(job *data.PendingJob, before any, currentCfg *data.CurrentConfig) bool {
+	verify := &currentCfg.Verify
+	clk := clock.Or(currentCfg.Clock)
+
+	for read := range max(verify.Attempts, 1) {
+		if read > 0 {
+			select {
+			case <-clk.After(verify.Interval):
+			case <-stopCtx.Done():
+				return false
+			}
+		}
+		err := compare(job, before, currentCfg)
+		if err == nil {
+			metrics.Add("wavely_verify_total", 1, "target", currentCfg.Name, "result", "ok")
+			logger.Log.Info("Schreiben bestätigt:", zap.String("uid", job.Job.UID), zap.String("mode", verify.Mode))
+			return true
+		}
+		logger.Log.Warn("Prüfung nach dem Schreiben fehlgeschlagen:", zap.String("uid", job.Job.UID), zap.Int("read", read+1), zap.Error(err))
+	}
+
+	metrics.Add("wavely_verify_total", 1, "target", currentCfg.Name, "result", "mismatch")
+	logger.Log.Error("Target hat den Payload nicht übernommen:", zap.String("uid", job.Job.UID), zap.String("mode", verify.Mode))
+	return false
+}
+
+// compare liest die Ressource einmal und vergleicht sie je nach Modus mit dem
+// Payload bzw. der Revision vor dem Schreiben.
+func compare(job *data.PendingJob, before any, currentCfg *data.CurrentConfig) error {
+	verify := &currentCfg.Verify
+	resp, ctx, err := readBack(job, currentCfg)
+	if err != nil {
+		return err
+	}
+
+	switch verify.Mode {
+	case data.VerifyField:
+		actual, err := extract(resp, verify.Field)
+		if err != nil {
+			return err
+		}
+		expected, err := expectedValue(verify, ctx, func() (string, error) {
+			// Derselbe Pfad im geschriebenen Payload
+			payload, err := ctx.Payload()
+			if err != nil {
+				return "", err
+			}
+			written := payloadValue([]byte(payload), cmp.Or(currentCfg.ContentType, job.Job.ContentType), currentCfg)
+			value, ok := convert.Path(written, strings.TrimPrefix(verify.Field, "body."))
+			if !ok {
+				return "", fmt.Errorf("%s fehlt im Payload", verify.Field)
+			}
+			return text(value), nil
+		})
+		if err != nil {
+			return err
+		}
+		if text(actual) != expected {
+			return fmt.Errorf("%s ist %s, erwartet %s", verify.Field, text(actual), expected)
+		}
+	case data.VerifyChecksum:
+		actual := checksum(verify.Algorithm, resp.Body)
+		if verify.Field != "" {
+			value, err := extract(resp, verify.Field)
+			if err != nil {
+				return err
+			}
+			// ETags stehen in Anführungszeichen
+			actual = strings.Trim(text(value), `"`)
+		}
+		expected, err := expectedValue(verify, ctx, func() (string, error) {
+			payload, err := ctx.Payload()
+			if err != nil {
+				return "", err
+			}
+			return checksum(verify.Algorithm, []byte(payload)), nil
+		})
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(actual, expected) {
+			return fmt.Errorf("Prüfsumme ist %s, erwartet %s", actual, expected)
+		}
+	case data.VerifyRevision:
+		after, err := extract(resp, verify.Field)
+		if err != nil {
+			return err
+		}
+		if !bumped(before, after) {
+			return fmt.Errorf("Revision unverändert: %s", text(after))
+		}
+	}
+	return nil
+}
+
+// readBack liest die Ressource über verify.endpoint.
+func readBack(job *data.PendingJob, currentCfg *data.CurrentConfig) (*external.Response, *stepContext, error) {
+	verify := &currentCfg.Verify
+	ctx, err := newStepContext(job, "", currentCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint, err := tmpl.Render(verify.Parsed, "endpoint", ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Endpunkt: %w", err)
+	}
+
+	resp, err := external.Call(external.Request{Method: http.MethodGet, Endpoint: endpoint, Limit: "verify"}, &job.Job, currentCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Status == http.StatusNotFound {
+		return nil, nil, errNotFound
+	}
+	if !successStatus(nil, resp.Status) {
+		return nil, nil, fmt.Errorf("unerwarteter Status %d, Body: %s", resp.Status, resp.Body)
+	}
+	return resp, ctx, nil
+}
+
+// expectedValue rendert verify.expected oder liefert den Standardwert des Modus.
+func expectedValue(verify *data.VerifyConfig, ctx *stepContext, fallback func() (string, error)) (string, error) {
+	if verify.Expected == "" {
+		return fallback()
+	}
+	expected, err := tmpl.Render(verify.Parsed, "expected", ctx)
+	if err != nil {
+		return "", fmt.Errorf("Erwarteter Wert: %w", err)
+	}
+	return expected, nil
+}
+
+func checksum(algorithm string, content []byte) string {
+	if cmp.Or(algorithm, data.ChecksumSHA256) == data.ChecksumMD5 {
+		sum := md5.Sum(content)
+		return hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// bumped meldet, ob sich die Revision geändert hat. Numerische Revisionen
+// müssen steigen.
+func bumped(before, after any) bool {
+	if after == nil || text(after) == "" {
+		return false
+	}
+	b, bok := before.(float64)
+	a, aok := after.(float64)
+	if bok && aok {
+		return a > b
+	}
+	return text(before) != text(after)
+}
+
+// text liefert Zeichenketten unverändert und alle anderen Werte als JSON.
+func text(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if value == nil {
+		return ""
+	}
+	out, _ := json.Marshal(value)
+	return string(out)
+}
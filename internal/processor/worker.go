@@ -2,32 +2,93 @@ package processor
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"djp.chapter42.de/a/internal/data"
+	"djp.chapter42.de/a/internal/logger"
+	"go.uber.org/zap"
 )
 
 // Todo:
-// - Export the worker delimiters to config
 // - Make the worker number dynamic
 // -- let it adapt to outside factors such as http-429
 
-const (
-	MinWorkers int = 5
-	MaxWorkers int = 10
-)
+// Aktive Konfiguration des Targets. Jeder Job übernimmt beim Start den dann
+// gültigen Stand und behält ihn bis zum Abschluss, auch über einen Reload hinweg.
+var activeCfg atomic.Pointer[data.CurrentConfig]
+
+var pool struct {
+	mu          sync.Mutex
+	pendingJobs *[]data.PendingJob
+	jobMutex    *sync.Mutex
+	quit        []chan struct{}
+	// Laufende Worker, einschließlich beendeter, die ihren Job noch abschließen
+	live atomic.Int32
+}
 
-var JobQueue = make(chan data.PendingJob, 100)
+func worker(pending_jobs *[]data.PendingJob, job_mutex *sync.Mutex, quit <-chan struct{}) {
+	defer pool.live.Add(-1)
 
-func worker(pending_jobs *[]data.PendingJob, job_mutex *sync.Mutex, current_cfg *data.CurrentConfig) {
-	for job := range JobQueue {
-		ProcessJob(job, pending_jobs, job_mutex, current_cfg)
+	for !Stopped() {
+		job, ok := JobQueue.PopUntil(quit)
+		if !ok {
+			return
+		}
+		ProcessJob(job, pending_jobs, job_mutex, activeCfg.Load())
 	}
 }
 
 func StartWorkerPool(pending_jobs *[]data.PendingJob, job_mutex *sync.Mutex, cfg *data.WavelyConfig) {
 	current := &cfg.Current
+	InitQueue(current.Name, current.Queue)
+	Delayed.clock = current.Clock
+	go Delayed.run()
+
+	pool.mu.Lock()
+	pool.pendingJobs = pending_jobs
+	pool.jobMutex = job_mutex
+	pool.mu.Unlock()
+
+	activeCfg.Store(current)
+	resize(current.MaxWorkers)
+}
+
+// Apply übernimmt eine neu geladene Konfiguration: Queue und Anzahl der Worker
+// werden angepasst, neue Jobs verwenden ab sofort current.
+func Apply(current *data.CurrentConfig) {
+	activeCfg.Store(current)
+	JobQueue.Configure(current.Queue.Capacity, current.Queue.Weights)
+	resize(current.MaxWorkers)
+}
+
+// resize startet zusätzliche Worker oder beendet überzählige. Ein beendeter
+// Worker schließt seinen aktuellen Job noch ab.
+func resize(workers int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.pendingJobs == nil || workers == len(pool.quit) {
+		return
+	}
+	logger.Log.Info("Anzahl der Worker geändert:", zap.Int("from", len(pool.quit)), zap.Int("to", workers))
 
-	for i := 0; i < current.MaxWorkers; i++ {
-		go worker(pending_jobs, job_mutex, current)
+	for len(pool.quit) < workers {
+		quit := make(chan struct{})
+		pool.quit = append(pool.quit, quit)
+		pool.live.Add(1)
+		go worker(pool.pendingJobs, pool.jobMutex, quit)
 	}
+	for len(pool.quit) > max(workers, 0) {
+		last := len(pool.quit) - 1
+		close(pool.quit[last])
+		pool.quit = pool.quit[:last]
+	}
+	// Auf einen Job wartende Worker sofort beenden
+	JobQueue.Wake()
+}
+
+// Workers liefert die Anzahl der laufenden Worker. Ein beendeter Worker zählt,
+// bis er seinen aktuellen Job abgeschlossen hat.
+func Workers() int {
+	return int(pool.live.Load())
 }
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResizeRetiresIdleWorkers(t *testing.T) {
+	queue := JobQueue
+	JobQueue = NewScheduler(10, nil)
+	var pendingJobs []data.PendingJob
+	var jobMutex sync.Mutex
+	pool.mu.Lock()
+	pool.pendingJobs, pool.jobMutex = &pendingJobs, &jobMutex
+	pool.mu.Unlock()
+	defer func() {
+		resize(0)
+		assert.Eventually(t, func() bool { return Workers() == 0 }, time.Second, time.Millisecond)
+		pool.mu.Lock()
+		pool.pendingJobs, pool.jobMutex = nil, nil
+		pool.mu.Unlock()
+		JobQueue = queue
+	}()
+
+	resize(3)
+	assert.Equal(t, 3, Workers())
+
+	// Auf einen Job wartende Worker enden sofort, nicht erst mit dem nächsten Job
+	resize(1)
+	assert.Eventually(t, func() bool { return Workers() == 1 }, time.Second, time.Millisecond)
+	assert.Len(t, pool.quit, 1)
+}
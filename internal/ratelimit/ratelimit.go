@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/metrics"
+)
+
+type Config struct {
+	Limit `mapstructure:",squash"`
+	// Zusätzliche Limits je Endpunkt (check, revision, write, fetch, verify, lock)
+	Endpoints map[string]Limit `mapstructure:"endpoints"`
+}
+
+type Limit struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// Limiter begrenzt die Anfragen aller Worker gegen ein Target.
+type Limiter struct {
+	target    string
+	global    *bucket
+	endpoints map[string]*bucket
+	// Uhr für das Auffüllen und Warten (nil: Systemuhr)
+	Clock clock.Clock
+}
+
+// Build liefert nil, wenn weder global noch je Endpunkt ein Limit gesetzt ist.
+func Build(target string, cfg Config) *Limiter {
+	l := &Limiter{target: target, global: newBucket(cfg.Limit), endpoints: make(map[string]*bucket)}
+	for endpoint, limit := range cfg.Endpoints {
+		if b := newBucket(limit); b != nil {
+			l.endpoints[endpoint] = b
+		}
+	}
+	if l.global == nil && len(l.endpoints) == 0 {
+		return nil
+	}
+
+	metrics.Describe("wavely_rate_limit_wait_seconds_total", "Time spent waiting for the per-target rate limiter.")
+	metrics.Describe("wavely_rate_limit_requests_total", "Requests that passed the per-target rate limiter.")
+	return l
+}
+
+// Acquire wartet, bis eine Anfrage an den Endpunkt erlaubt ist, und liefert die
+// Wartezeit. Endet ctx vorher, werden die Reservierungen zurückgegeben und der
+// Fehler des Kontexts geliefert. Ein nil-Limiter lässt alle Anfragen sofort durch.
+func (l *Limiter) Acquire(ctx context.Context, endpoint string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	clk := clock.Or(l.Clock)
+	now := clk.Now()
+	endpointBucket := l.endpoints[endpoint]
+	wait := max(l.global.reserve(now), endpointBucket.reserve(now))
+	if wait > 0 {
+		select {
+		case <-clk.After(wait):
+		case <-ctx.Done():
+			l.global.cancel()
+			endpointBucket.cancel()
+			return 0, ctx.Err()
+		}
+	}
+
+	metrics.Add("wavely_rate_limit_wait_seconds_total", wait.Seconds(), "target", l.target, "endpoint", endpoint)
+	metrics.Add("wavely_rate_limit_requests_total", 1, "target", l.target, "endpoint", endpoint)
+	return wait, nil
+}
+
+// bucket ist ein Token-Bucket. Negative Token-Stände stehen für bereits
+// reservierte Anfragen, so werden wartende Worker der Reihe nach bedient.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit Limit) *bucket {
+	if limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: limit.RequestsPerSecond, burst: burst, tokens: burst}
+}
+
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Der erste Aufruf findet einen vollen Bucket vor
+	if b.last.IsZero() {
+		b.last = now
+	}
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel gibt eine nicht genutzte Reservierung zurück.
+func (b *bucket) cancel() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
@@ -0,0 +1,115 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/clock"
+	"djp.chapter42.de/a/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+// acquire startet Acquire im Hintergrund, da es auf der Uhr schläft.
+func acquire(l *ratelimit.Limiter, endpoint string) <-chan time.Duration {
+	done := make(chan time.Duration, 1)
+	go func() {
+		wait, _ := l.Acquire(context.Background(), endpoint)
+		done <- wait
+	}()
+	return done
+}
+
+// acquireNow ruft Acquire für Anfragen auf, die nicht warten müssen.
+func acquireNow(t *testing.T, l *ratelimit.Limiter, endpoint string) time.Duration {
+	wait, err := l.Acquire(context.Background(), endpoint)
+	assert.NoError(t, err)
+	return wait
+}
+
+func newLimiter(cfg ratelimit.Config) (*ratelimit.Limiter, *clock.Fake) {
+	fake := clock.NewFake(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	l := ratelimit.Build("ratelimit-target", cfg)
+	l.Clock = fake
+	return l, fake
+}
+
+func TestLimiterBurst(t *testing.T) {
+	l, fake := newLimiter(ratelimit.Config{Limit: ratelimit.Limit{RequestsPerSecond: 2, Burst: 3}})
+
+	// Der volle Bucket lässt burst Anfragen ohne Wartezeit durch
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, acquireNow(t, l, "write"))
+	}
+
+	// Danach kommt eine Anfrage je 1/rate Sekunden durch, der Reihe nach
+	first := acquire(l, "write")
+	fake.BlockUntil(1)
+	second := acquire(l, "write")
+	fake.BlockUntil(2)
+	fake.Advance(500 * time.Millisecond)
+	assert.Equal(t, 500*time.Millisecond, <-first)
+	fake.Advance(500 * time.Millisecond)
+	assert.Equal(t, time.Second, <-second)
+
+	// Nach einer Pause ist der Bucket wieder voll, aber nicht über burst hinaus
+	fake.Advance(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, acquireNow(t, l, "write"))
+	}
+	third := acquire(l, "write")
+	fake.BlockUntil(1)
+	fake.Advance(500 * time.Millisecond)
+	assert.Equal(t, 500*time.Millisecond, <-third)
+}
+
+func TestLimiterEndpoints(t *testing.T) {
+	l, fake := newLimiter(ratelimit.Config{
+		Limit:     ratelimit.Limit{RequestsPerSecond: 10, Burst: 10},
+		Endpoints: map[string]ratelimit.Limit{"check": {RequestsPerSecond: 1}},
+	})
+
+	// Das strengere Limit des Endpunkts gilt zusätzlich zum globalen
+	assert.Zero(t, acquireNow(t, l, "check"))
+	check := acquire(l, "check")
+	fake.BlockUntil(1)
+
+	// Andere Endpunkte unterliegen nur dem globalen Limit
+	assert.Zero(t, acquireNow(t, l, "write"))
+	fake.Advance(time.Second)
+	assert.Equal(t, time.Second, <-check)
+
+	// Ein Burst unter 1 erlaubt trotzdem eine Anfrage
+	l, _ = newLimiter(ratelimit.Config{Endpoints: map[string]ratelimit.Limit{"lock": {RequestsPerSecond: 5, Burst: -1}}})
+	assert.Zero(t, acquireNow(t, l, "lock"))
+	assert.Zero(t, acquireNow(t, l, "write"))
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	// Ohne Limits gibt es keinen Limiter, und nil lässt alles sofort durch
+	l := ratelimit.Build("ratelimit-target", ratelimit.Config{Endpoints: map[string]ratelimit.Limit{"check": {Burst: 5}}})
+	assert.Nil(t, l)
+	for i := 0; i < 100; i++ {
+		assert.Zero(t, acquireNow(t, l, "check"))
+	}
+}
+
+func TestLimiterCancelled(t *testing.T) {
+	l, fake := newLimiter(ratelimit.Config{Limit: ratelimit.Limit{RequestsPerSecond: 1, Burst: 1}})
+	assert.Zero(t, acquireNow(t, l, "write"))
+
+	// Ein abgebrochenes Warten liefert den Fehler des Kontexts
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, "write")
+		done <- err
+	}()
+	fake.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// Die Reservierung wurde zurückgegeben, nach 1/rate ist wieder ein Token frei
+	fake.Advance(time.Second)
+	assert.Zero(t, acquireNow(t, l, "write"))
+}
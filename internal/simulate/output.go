@@ -0,0 +1,125 @@
+package simulate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 72
+	chartHeight = 12
+
+	svgWidth  = 800
+	svgHeight = 300
+	svgMargin = 40
+)
+
+var svgColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e"}
+
+// WriteCSV schreibt die Anfragen je Bucket, eine Spalte je Strategie.
+func WriteCSV(w io.Writer, series []Series, bucket time.Duration) error {
+	out := csv.NewWriter(w)
+
+	header := []string{"second"}
+	for _, s := range series {
+		header = append(header, s.Strategy)
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for i := range series[0].Counts {
+		row := []string{strconv.FormatFloat((time.Duration(i) * bucket).Seconds(), 'f', -1, 64)}
+		for _, s := range series {
+			row = append(row, strconv.Itoa(s.Counts[i]))
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteChart zeichnet je Strategie ein ASCII-Balkendiagramm. Bei mehr Buckets
+// als Spalten zeigt jede Spalte das Maximum der zusammengefassten Buckets, damit
+// Spitzen nicht verschwinden.
+func WriteChart(w io.Writer, series []Series, bucket time.Duration) {
+	for _, s := range series {
+		columns := downsample(s.Counts, chartWidth)
+		peak := 0
+		for _, c := range columns {
+			peak = max(peak, c)
+		}
+
+		fmt.Fprintf(w, "%s: peak %d, mittel %.2f, peak/mittel %.2f je %s\n", s.Strategy, s.Peak(), s.Mean(), s.PeakToAverage(), bucket)
+		for row := chartHeight; row > 0; row-- {
+			var line strings.Builder
+			for _, c := range columns {
+				if peak > 0 && c*chartHeight >= row*peak {
+					line.WriteByte('#')
+				} else {
+					line.WriteByte(' ')
+				}
+			}
+			label := ""
+			if row == chartHeight {
+				label = strconv.Itoa(peak)
+			}
+			fmt.Fprintf(w, "%5s |%s\n", label, strings.TrimRight(line.String(), " "))
+		}
+		fmt.Fprintf(w, "%5s +%s\n", "", strings.Repeat("-", len(columns)))
+		fmt.Fprintf(w, "%5s  0%*s\n\n", "", len(columns)-1, time.Duration(len(s.Counts))*bucket)
+	}
+}
+
+// WriteSVG zeichnet alle Strategien als Linien in ein gemeinsames Diagramm.
+func WriteSVG(w io.Writer, series []Series, bucket time.Duration) error {
+	peak := 1
+	for _, s := range series {
+		peak = max(peak, s.Peak())
+	}
+	plotWidth := float64(svgWidth - 2*svgMargin)
+	plotHeight := float64(svgHeight - 2*svgMargin)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", svgWidth, svgHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", svgMargin, svgHeight-svgMargin, svgWidth-svgMargin, svgHeight-svgMargin)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", svgMargin, svgMargin, svgMargin, svgHeight-svgMargin)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%d</text>`+"\n", svgMargin-4, svgMargin+4, peak)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgWidth-svgMargin, svgHeight-svgMargin+16, time.Duration(len(series[0].Counts))*bucket)
+
+	for i, s := range series {
+		color := svgColors[i%len(svgColors)]
+		points := make([]string, len(s.Counts))
+		for j, c := range s.Counts {
+			x := float64(svgMargin) + plotWidth*float64(j)/float64(max(len(s.Counts)-1, 1))
+			y := float64(svgHeight-svgMargin) - plotHeight*float64(c)/float64(peak)
+			points[j] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1" points="%s"/>`+"\n", color, strings.Join(points, " "))
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s (peak/mittel %.2f)</text>`+"\n", svgMargin+10, svgMargin+16*(i+1), color, s.Strategy, s.PeakToAverage())
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func downsample(counts []int, width int) []int {
+	if len(counts) <= width {
+		return counts
+	}
+	columns := make([]int, width)
+	for i, c := range counts {
+		col := i * width / len(counts)
+		columns[col] = max(columns[col], c)
+	}
+	return columns
+}
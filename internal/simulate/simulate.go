@@ -0,0 +1,182 @@
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	timebackoff "djp.chapter42.de/a/internal/time_backoff"
+)
+
+const (
+	StrategySinus       = "sinus"
+	StrategyExponential = "exponential"
+
+	ArrivalBurst   = "burst"
+	ArrivalUniform = "uniform"
+	ArrivalPoisson = "poisson"
+)
+
+// Options beschreibt einen Simulationslauf: Backoff-Parameter, Anzahl der Jobs
+// und das Muster, in dem die Jobs eintreffen.
+type Options struct {
+	Strategies  []string
+	Jobs        int
+	Arrival     string
+	Window      time.Duration // Zeitraum, über den die Jobs eintreffen
+	Horizon     time.Duration
+	Bucket      time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Oscillation int
+	Jitter      float64
+	Allocate    bool // Phasen über den PhaseAllocator vergeben statt zufällig
+	Seed        uint64
+}
+
+// Series ist die Lastkurve einer Strategie: Anfragen je Bucket.
+type Series struct {
+	Strategy string
+	Counts   []int
+}
+
+func (s Series) Peak() int {
+	peak := 0
+	for _, c := range s.Counts {
+		peak = max(peak, c)
+	}
+	return peak
+}
+
+func (s Series) Mean() float64 {
+	if len(s.Counts) == 0 {
+		return 0
+	}
+	total := 0
+	for _, c := range s.Counts {
+		total += c
+	}
+	return float64(total) / float64(len(s.Counts))
+}
+
+func (s Series) PeakToAverage() float64 {
+	return timebackoff.PeakToAverage(s.Counts)
+}
+
+// DefaultOptions entspricht dem Verhalten der Worker.
+func DefaultOptions() Options {
+	probe := timebackoff.RestoreSinusBackoff(0, 0)
+	return Options{
+		Strategies:  []string{StrategySinus, StrategyExponential},
+		Jobs:        100,
+		Arrival:     ArrivalBurst,
+		Window:      time.Minute,
+		Horizon:     10 * time.Minute,
+		Bucket:      time.Second,
+		BaseDelay:   timebackoff.BaseDelay,
+		MaxDelay:    timebackoff.MaxDelay,
+		Oscillation: probe.Oscillation,
+		Jitter:      timebackoff.JitterFactor,
+		Allocate:    true,
+		Seed:        1,
+	}
+}
+
+func (o Options) validate() error {
+	if len(o.Strategies) == 0 {
+		return fmt.Errorf("keine Strategie angegeben")
+	}
+	for _, s := range o.Strategies {
+		if s != StrategySinus && s != StrategyExponential {
+			return fmt.Errorf("unbekannte Strategie: %s", s)
+		}
+	}
+	switch o.Arrival {
+	case ArrivalBurst, ArrivalUniform, ArrivalPoisson:
+	default:
+		return fmt.Errorf("unbekanntes Ankunftsmuster: %s", o.Arrival)
+	}
+	switch {
+	case o.Jobs <= 0:
+		return fmt.Errorf("jobs muss größer als 0 sein")
+	case o.Bucket <= 0 || o.Horizon < o.Bucket:
+		return fmt.Errorf("horizon muss mindestens einen bucket umfassen")
+	case o.BaseDelay <= 0 || o.MaxDelay < o.BaseDelay:
+		return fmt.Errorf("base_delay muss größer als 0 und höchstens max_delay sein")
+	case o.Oscillation <= 0:
+		return fmt.Errorf("oscillation muss größer als 0 sein")
+	case o.Jitter < 0:
+		return fmt.Errorf("jitter darf nicht negativ sein")
+	}
+	return nil
+}
+
+// Run simuliert die Strategien gegen eine virtuelle Uhr: alle Jobs schlagen bis
+// zum Horizont fehl und fragen nach ihrem Backoff erneut an. Mit gleichem Seed
+// liefern alle Strategien dieselben Ankunftszeiten.
+func Run(opts Options) ([]Series, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	starts := arrivals(opts, rand.New(rand.NewPCG(opts.Seed, 0)))
+
+	series := make([]Series, 0, len(opts.Strategies))
+	for _, name := range opts.Strategies {
+		rng := rand.New(rand.NewPCG(opts.Seed, 1))
+		phases := &timebackoff.PhaseAllocator{}
+
+		strategies := make([]timebackoff.Strategy, opts.Jobs)
+		for i := range strategies {
+			jitter := rng.Float64() * opts.Jitter
+			if name == StrategyExponential {
+				strategies[i] = &timebackoff.CappedExponentialBackoff{BaseDelay: opts.BaseDelay, MaxDelay: opts.MaxDelay, JitterFactor: jitter}
+				continue
+			}
+
+			phase := rng.Float64() * 2 * math.Pi
+			if opts.Allocate {
+				phase = phases.Allocate()
+			}
+			b := timebackoff.RestoreSinusBackoff(phase, jitter)
+			b.BaseDelay, b.MaxDelay, b.Oscillation = opts.BaseDelay, opts.MaxDelay, opts.Oscillation
+			strategies[i] = b
+		}
+
+		series = append(series, Series{Strategy: name, Counts: timebackoff.LoadCurve(strategies, starts, opts.Horizon, opts.Bucket)})
+	}
+	return series, nil
+}
+
+// arrivals liefert die Startzeitpunkte der Jobs innerhalb des Zeitfensters.
+func arrivals(opts Options, rng *rand.Rand) []time.Duration {
+	starts := make([]time.Duration, opts.Jobs)
+	switch opts.Arrival {
+	case ArrivalUniform:
+		for i := range starts {
+			starts[i] = time.Duration(float64(opts.Window) * float64(i) / float64(opts.Jobs))
+		}
+	case ArrivalPoisson:
+		// Exponentialverteilte Abstände mit mittlerer Rate Jobs/Window
+		mean := float64(opts.Window) / float64(opts.Jobs)
+		var t float64
+		for i := range starts {
+			t += rng.ExpFloat64() * mean
+			starts[i] = time.Duration(t)
+		}
+	}
+	return starts
+}
+
+// ParseStrategies zerlegt eine kommagetrennte Liste von Strategien.
+func ParseStrategies(list string) []string {
+	var strategies []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(strings.ToLower(s)); s != "" {
+			strategies = append(strategies, s)
+		}
+	}
+	return strategies
+}
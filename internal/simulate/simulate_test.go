@@ -0,0 +1,37 @@
+package simulate_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"djp.chapter42.de/a/internal/simulate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulate(t *testing.T) {
+	opts := simulate.DefaultOptions()
+	opts.Jobs = 50
+	opts.Horizon = 2 * time.Minute
+
+	series, err := simulate.Run(opts)
+	assert.NoError(t, err)
+	assert.Len(t, series, 2)
+	assert.Len(t, series[0].Counts, 120)
+
+	// Gleicher Seed, gleiche Lastkurve
+	again, _ := simulate.Run(opts)
+	assert.Equal(t, series, again)
+
+	// Beim Burst treffen alle exponentiellen Jobs gleichzeitig ein
+	assert.Less(t, series[0].PeakToAverage(), series[1].PeakToAverage())
+
+	var out bytes.Buffer
+	assert.NoError(t, simulate.WriteCSV(&out, series, opts.Bucket))
+	assert.True(t, strings.HasPrefix(out.String(), "second,sinus,exponential\n0,"))
+
+	opts.Arrival = "storm"
+	_, err = simulate.Run(opts)
+	assert.Error(t, err)
+}
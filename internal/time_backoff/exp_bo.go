@@ -9,4 +9,20 @@ import (
 
 func ExponentialBackoff(attempts int) time.Duration {
 	return time.Duration(math.Pow(2, float64(attempts))) * time.Second
-}
\ No newline at end of file
+}
+
+// CappedExponentialBackoff verdoppelt die Verzögerung ab BaseDelay bis höchstens
+// MaxDelay und erlaubt so den Vergleich mit dem Sinus-Backoff.
+type CappedExponentialBackoff struct {
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	JitterFactor float64
+}
+
+func (b *CappedExponentialBackoff) CalculateBackoff(attempt int) time.Duration {
+	delay := time.Duration(float64(b.BaseDelay) * math.Pow(2, float64(attempt)))
+	if delay <= 0 || delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay + time.Duration(b.JitterFactor*float64(delay))
+}
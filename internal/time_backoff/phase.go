@@ -0,0 +1,124 @@
+package timebackoff
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PhaseAllocator verteilt die Phasen aller aktiven Jobs eines Targets gleichmäßig
+// über die volle Periode. Ein neuer Job erhält jeweils die Mitte der größten Lücke;
+// ohne Abgänge ergibt das eine Folge geringer Diskrepanz, bei Abgängen werden die
+// frei gewordenen Lücken zuerst wieder aufgefüllt.
+type PhaseAllocator struct {
+	mu     sync.Mutex
+	phases []float64 // sortiert, in [0, 2π)
+}
+
+var (
+	allocatorsMu sync.Mutex
+	allocators   = make(map[string]*PhaseAllocator)
+)
+
+// AllocatorFor liefert den Phasen-Allocator eines Targets.
+func AllocatorFor(target string) *PhaseAllocator {
+	allocatorsMu.Lock()
+	defer allocatorsMu.Unlock()
+
+	a, ok := allocators[target]
+	if !ok {
+		a = &PhaseAllocator{}
+		allocators[target] = a
+	}
+	return a
+}
+
+// Allocate vergibt die Phase für einen neuen Job.
+func (a *PhaseAllocator) Allocate() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	phase := 0.0
+	if n := len(a.phases); n > 0 {
+		// Lücke hinter der letzten Phase schließt über 2π an die erste an
+		bestStart := a.phases[n-1]
+		bestGap := a.phases[0] + 2*math.Pi - a.phases[n-1]
+		for i := 1; i < n; i++ {
+			if gap := a.phases[i] - a.phases[i-1]; gap > bestGap {
+				bestStart, bestGap = a.phases[i-1], gap
+			}
+		}
+		phase = math.Mod(bestStart+bestGap/2, 2*math.Pi)
+	}
+
+	a.insert(phase)
+	return phase
+}
+
+// Reserve meldet die Phase eines wiederhergestellten Jobs an.
+func (a *PhaseAllocator) Reserve(phase float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.insert(math.Mod(phase, 2*math.Pi))
+}
+
+// Release gibt die Phase eines abgeschlossenen Jobs wieder frei.
+func (a *PhaseAllocator) Release(phase float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	phase = math.Mod(phase, 2*math.Pi)
+	i := sort.SearchFloat64s(a.phases, phase)
+	if i < len(a.phases) && a.phases[i] == phase {
+		a.phases = append(a.phases[:i], a.phases[i+1:]...)
+	}
+}
+
+func (a *PhaseAllocator) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.phases)
+}
+
+func (a *PhaseAllocator) insert(phase float64) {
+	i := sort.SearchFloat64s(a.phases, phase)
+	a.phases = append(a.phases, 0)
+	copy(a.phases[i+1:], a.phases[i:])
+	a.phases[i] = phase
+}
+
+// LoadCurve simuliert Jobs, die ab start durchgehend fehlschlagen und nach ihrem
+// Backoff erneut anfragen, und zählt die Anfragen je bucket bis zum horizon.
+// Damit lässt sich prüfen, wie gleichmäßig die Last auf dem Target verteilt ist.
+func LoadCurve(backoffs []Strategy, starts []time.Duration, horizon, bucket time.Duration) []int {
+	counts := make([]int, int(horizon/bucket))
+	for i, b := range backoffs {
+		var t time.Duration
+		if i < len(starts) {
+			t = starts[i]
+		}
+		for attempt := 0; ; attempt++ {
+			t += b.CalculateBackoff(attempt)
+			if t >= horizon {
+				break
+			}
+			counts[int(t/bucket)]++
+		}
+	}
+	return counts
+}
+
+// PeakToAverage liefert das Verhältnis von Spitzenlast zu mittlerer Last einer
+// Lastkurve; 1 entspricht einer völlig flachen Kurve.
+func PeakToAverage(counts []int) float64 {
+	peak, total := 0, 0
+	for _, c := range counts {
+		total += c
+		peak = max(peak, c)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(peak) / (float64(total) / float64(len(counts)))
+}
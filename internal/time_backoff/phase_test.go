@@ -0,0 +1,46 @@
+package timebackoff_test
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+	"time"
+
+	timebackoff "djp.chapter42.de/a/internal/time_backoff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseAllocation(t *testing.T) {
+	phases := &timebackoff.PhaseAllocator{}
+	allocated := make([]float64, 8)
+	for i := range allocated {
+		allocated[i] = phases.Allocate()
+	}
+
+	// Acht Phasen teilen die Periode in gleich große Lücken
+	sort.Float64s(allocated)
+	for i := 1; i < len(allocated); i++ {
+		assert.InDelta(t, math.Pi/4, allocated[i]-allocated[i-1], 1e-9)
+	}
+
+	// Eine freigegebene Phase wird als Nächstes wieder vergeben
+	phases.Release(allocated[3])
+	assert.InDelta(t, allocated[3], phases.Allocate(), 1e-9)
+	assert.Equal(t, 8, phases.Len())
+
+	// Lastkurve: zugeteilte Phasen gegenüber gehäuften Phasen wie bisher
+	const jobs = 200
+	rng := rand.New(rand.NewPCG(42, 0))
+	spread := &timebackoff.PhaseAllocator{}
+	var even, clustered []timebackoff.Strategy
+	for range jobs {
+		even = append(even, timebackoff.RestoreSinusBackoff(spread.Allocate(), 0))
+		clustered = append(clustered, timebackoff.RestoreSinusBackoff(rng.Float64(), 0))
+	}
+
+	evenRatio := timebackoff.PeakToAverage(timebackoff.LoadCurve(even, nil, 2*time.Minute, time.Second))
+	clusteredRatio := timebackoff.PeakToAverage(timebackoff.LoadCurve(clustered, nil, 2*time.Minute, time.Second))
+	assert.Less(t, evenRatio, 4.0)
+	assert.Less(t, evenRatio, clusteredRatio)
+}
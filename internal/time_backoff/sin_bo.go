@@ -2,8 +2,9 @@ package timebackoff
 
 import (
 	"math"
-	"math/rand/v2"
 	"time"
+
+	"djp.chapter42.de/a/internal/clock"
 )
 
 // Konstante für die Oszillation
@@ -17,12 +18,31 @@ const (
 )
 
 type SinusBackoff struct {
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
 	Oscillation  int
 	PhaseShift   float64
 	JitterFactor float64
 }
 
-func NewSinusBackoff() *SinusBackoff {
+// NewSinusBackoff zieht Phase und Jitter aus rng (nil: globale Zufallsquelle).
+func NewSinusBackoff(rng *clock.Random) *SinusBackoff {
+	phaseShift := rng.Float64() * 2 * math.Pi
+	jitter := rng.Float64() * float64(JitterFactor)
+
+	return RestoreSinusBackoff(phaseShift, jitter)
+}
+
+// NewAllocatedSinusBackoff bezieht die Phase vom Allocator des Targets, damit
+// die Jobs eines Targets gleichmäßig über die Periode verteilt sind.
+func NewAllocatedSinusBackoff(allocator *PhaseAllocator, rng *clock.Random) *SinusBackoff {
+	jitter := rng.Float64() * float64(JitterFactor)
+	return RestoreSinusBackoff(allocator.Allocate(), jitter)
+}
+
+// RestoreSinusBackoff setzt einen Backoff mit bekannter Phase fort, z.B. nach
+// einem Neustart.
+func RestoreSinusBackoff(phaseShift, jitter float64) *SinusBackoff {
 	oscillation := int((int(MaxDelay / BaseDelay) + int (MaxOscillation / MinOscillation)) / 2)
 	if oscillation < MinOscillation {
 		oscillation = MinOscillation
@@ -31,10 +51,9 @@ func NewSinusBackoff() *SinusBackoff {
 		oscillation = MaxOscillation
 	}
 
-	phaseShift := rand.Float64()
-	jitter := rand.Float64() * float64(JitterFactor)
-
 	return &SinusBackoff{
+		BaseDelay:    BaseDelay,
+		MaxDelay:     MaxDelay,
 		Oscillation:  oscillation,
 		PhaseShift:   phaseShift,
 		JitterFactor: jitter,
@@ -48,7 +67,7 @@ func (b *SinusBackoff) CalculateBackoff(attempt int) time.Duration {
 	sinFactor := math.Sin((float64(attempt)*(math.Pi/float64(b.Oscillation))) + b.PhaseShift - (math.Pi / 2)) 
 
 	// Normalisieren: sin(x) liegt zwischen -1 und 1 → skaliere auf [baseDelay, maxDelay]
-	delay := BaseDelay + time.Duration((sinFactor+1.0)*float64(MaxDelay-BaseDelay)/2.0)
+	delay := b.BaseDelay + time.Duration((sinFactor+1.0)*float64(b.MaxDelay-b.BaseDelay)/2.0)
 
 	// Füge Zufallseinfluss (Jitter) hinzu, um kleine Schwankungen zu erzeugen
 	jitter := time.Duration(b.JitterFactor * float64(delay)) // Zufälliger Jitter
@@ -14,4 +14,9 @@ func Max(a, b time.Duration) time.Duration {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
+
+// Strategy ist eine Backoff-Strategie, die die Wartezeit vor einem Versuch liefert.
+type Strategy interface {
+	CalculateBackoff(attempt int) time.Duration
+}
@@ -2,9 +2,19 @@ package tmpl
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
 
+	"djp.chapter42.de/a/internal/convert"
 	"djp.chapter42.de/a/internal/data"
 )
 
@@ -28,10 +38,189 @@ func PrepareTemplates(cfg *data.WavelyConfig) error {
 	current.ParsedRevisionTpl = revsionTpl
 	current.ParsedWriteTpl = writeTpl
 
+	for i := range current.Pipeline {
+		step := &current.Pipeline[i]
+		parsed, err := ParseStep(*step)
+		if err != nil {
+			return fmt.Errorf("error in pipeline step %s [%s]: %w", step.Name, current.Name, err)
+		}
+		step.Parsed = parsed
+	}
+
+	lock := &current.Lock
+	for name, request := range map[string]*data.RequestConfig{"acquire": &lock.Acquire, "renew": &lock.Renew, "release": &lock.Release} {
+		if request.Endpoint == "" {
+			continue
+		}
+		parsed, err := ParseStep(request.Step(name))
+		if err != nil {
+			return fmt.Errorf("error in lock %s template [%s]: %w", name, current.Name, err)
+		}
+		request.Parsed = parsed
+	}
+
+	if current.Transform != "" {
+		parsed, err := parseParts("transform", map[string]string{"transform": current.Transform})
+		if err != nil {
+			return fmt.Errorf("error in transform template [%s]: %w", current.Name, err)
+		}
+		// encode schreibt mit den Einstellungen des Targets
+		current.ParsedTransform = parsed.Funcs(texttemplate.FuncMap{"encode": encoder(current.Convert)})
+	}
+
+	if verify := &current.Verify; verify.Mode != "" {
+		parsed, err := parseParts("verify", map[string]string{"endpoint": verify.Endpoint, "expected": verify.Expected})
+		if err != nil {
+			return fmt.Errorf("error in verify template [%s]: %w", current.Name, err)
+		}
+		verify.Parsed = parsed
+	}
+
 	return nil
 }
 
+// StepFuncs sind die Funktionen in Templates der Pipeline und der
+// Transformation, z.B. {"revision": {{json .Steps.revision.rev}}}:
+//
+//	json v                 Wert als JSON
+//	encode format v        Wert in einem Format des Konverters (xml, yaml, ...)
+//	get v pfad             Wert unter einem Pfad wie items.0.id, sonst nil
+//	default d v            v oder d, falls v fehlt bzw. leer ist
+//	upper, lower, trim s   Groß-/Kleinschreibung, Leerraum entfernen
+//	replace alt neu s      alle Vorkommen ersetzen
+//	join sep liste         Liste zu Text verbinden
+//	split sep s            Text in eine Liste teilen
+//	b64enc, b64dec s       Base64
+//	date layout v          Zeitpunkt (RFC 3339) im Go-Layout formatieren
+//	add a b                Zahlen addieren
+var StepFuncs = texttemplate.FuncMap{
+	"json": func(v any) (string, error) {
+		// Ohne HTML-Maskierung, Bodies können z.B. XML enthalten
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n"), err
+	},
+	"encode": encoder(convert.Options{}),
+	"get": func(v any, path string) any {
+		value, _ := convert.Path(v, path)
+		return value
+	},
+	"default": func(d, v any) any {
+		if v == nil || v == "" {
+			return d
+		}
+		return v
+	},
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"join": func(sep string, items any) (string, error) {
+		switch list := items.(type) {
+		case []string:
+			return strings.Join(list, sep), nil
+		case []any:
+			parts := make([]string, len(list))
+			for i, item := range list {
+				parts[i] = fmt.Sprint(item)
+			}
+			return strings.Join(parts, sep), nil
+		}
+		return "", fmt.Errorf("join erwartet eine Liste, nicht %T", items)
+	},
+	"split":  func(sep, s string) []string { return strings.Split(s, sep) },
+	"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec": func(s string) (string, error) {
+		out, err := base64.StdEncoding.DecodeString(s)
+		return string(out), err
+	},
+	"date": func(layout string, v any) (string, error) {
+		t, ok := v.(time.Time)
+		if !ok {
+			var err error
+			if t, err = time.Parse(time.RFC3339, fmt.Sprint(v)); err != nil {
+				return "", err
+			}
+		}
+		return t.Format(layout), nil
+	},
+	"add": func(a, b any) (json.Number, error) {
+		x, err := number(a)
+		if err != nil {
+			return "", err
+		}
+		y, err := number(b)
+		if err != nil {
+			return "", err
+		}
+		return json.Number(strconv.FormatFloat(x+y, 'f', -1, 64)), nil
+	},
+}
+
+// encoder liefert die Funktion encode für die Einstellungen eines Targets.
+func encoder(opts convert.Options) func(format string, v any) (string, error) {
+	return func(format string, v any) (string, error) {
+		out, err := convert.Encode(v, format, opts)
+		return string(out), err
+	}
+}
+
+func number(v any) (float64, error) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	}
+	return strconv.ParseFloat(fmt.Sprint(v), 64)
+}
+
+// ParseStep bereitet die Templates eines Pipeline-Schritts vor. Anders als bei
+// den Endpunkten wird nicht HTML-maskiert, da auch Bodies erzeugt werden.
+// Fehlende Ausgaben früherer Schritte führen beim Rendern zu einem Fehler.
+func ParseStep(step data.StepConfig) (*texttemplate.Template, error) {
+	parts := map[string]string{
+		"endpoint":  step.Endpoint,
+		"body":      step.Body,
+		"condition": step.Success.Condition,
+	}
+	for name, value := range step.Headers {
+		parts["header:"+name] = value
+	}
+	return parseParts(step.Name, parts)
+}
+
+func parseParts(name string, parts map[string]string) (*texttemplate.Template, error) {
+	t := texttemplate.New(name).Funcs(StepFuncs).Option("missingkey=error")
+	for _, part := range slices.Sorted(maps.Keys(parts)) {
+		if _, err := t.New(part).Parse(parts[part]); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Render führt ein Template eines vorbereiteten Pipeline-Schritts aus.
+func Render(t *texttemplate.Template, name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ErrNoEndpoint meldet einen Endpunkt, der nicht konfiguriert ist. Seit es
+// Pipelines gibt, sind die Endpunkte optional.
+var ErrNoEndpoint = errors.New("Endpunkt ist nicht konfiguriert")
+
+// RenderEndpoint rendert den Pfad eines Endpunkts. Ein nicht konfigurierter
+// Endpunkt (nil) ist ein Fehler, statt still die Basis-URL zu verwenden.
 func RenderEndpoint(tpl *template.Template, job data.Job) (string, error) {
+	if tpl == nil {
+		return "", ErrNoEndpoint
+	}
 	var buf bytes.Buffer
 	if err := tpl.Execute(&buf, job); err != nil {
 		return "", err